@@ -1,145 +1,102 @@
 package httpkit
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"time"
-
-	"github.com/moul/http2curl"
 )
 
 type AdvanceHttpClient struct {
 	host   string
 	scheme string
-	client *http.Client
+	engine *engine
 }
 
 func NewAdvanceHttpClient(scheme, host string, connTimeout time.Duration, tlsCfg *tls.Config) *AdvanceHttpClient {
-	tr := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   connTimeout,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-
-		TLSClientConfig:       tlsCfg,
-		DisableKeepAlives:     false,
-		MaxIdleConns:          500,
-		MaxIdleConnsPerHost:   300,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-
-	client := &http.Client{
-		Transport: tr,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
 	return &AdvanceHttpClient{
 		scheme: scheme,
 		host:   host,
-		client: client,
+		engine: newEngine(nil, newTransport(connTimeout, tlsCfg, 500, 300)),
 	}
 }
 
+// NewAdvanceHttpClientWithTransport transport为nil时使用默认的transport
 func NewAdvanceHttpClientWithTransport(
 	scheme, host string, connTimeout time.Duration, tlsCfg *tls.Config,
 	transport http.RoundTripper,
 ) *AdvanceHttpClient {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	if transport != nil {
-		client.Transport = transport
-	} else {
-		client.Transport = &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   connTimeout,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-
-			TLSClientConfig:       tlsCfg,
-			DisableKeepAlives:     false,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
-	}
-
 	return &AdvanceHttpClient{
 		scheme: scheme,
 		host:   host,
-		client: client,
+		engine: newEngine(transport, newTransport(connTimeout, tlsCfg, 100, 0)),
 	}
 }
 
 type AdvanceSettings struct {
-	readWriteTimeout  time.Duration
-	path              string
-	params            url.Values
-	headers           http.Header
-	cookie            *http.Cookie
-	body              io.Reader
-	rawBody           []byte
-	baseAuth          bool
-	baseAuthUsername  string
-	baseAuthPassword  string
-	gzip              bool
-	retry             int
-	retryInterval     time.Duration
-	retryHttpStatuses []int // 重试的http状态码
+	requestOptions
 }
 
 type AdvanceResponse struct {
-	Body       []byte
-	Header     http.Header
-	StatusCode int
-	Status     string
-	Time       int64
+	Body        []byte
+	Header      http.Header
+	StatusCode  int
+	Status      string
+	Time        int64
+	CacheStatus CacheStatus   // 开启缓存时表示响应来自缓存、校验后的缓存还是服务端
+	Redirects   []RedirectHop // 设置跳转策略时实际跟随的跳转链
+}
+
+func (client *AdvanceHttpClient) EnableOtelHttp() *AdvanceHttpClient {
+	client.engine.enableOtelHttp()
+	return client
+}
+
+// SetProxy 设置代理，默认使用HTTP_PROXY等环境变量，使用自定义transport时不生效
+// 需在发起请求前设置
+func (client *AdvanceHttpClient) SetProxy(proxy ProxyFunc) *AdvanceHttpClient {
+	client.engine.setProxy(proxy)
+	return client
+}
+
+// EnableCassette 开启录制回放，回放时不访问网络
+func (client *AdvanceHttpClient) EnableCassette(cassette *Cassette) *AdvanceHttpClient {
+	client.engine.enableCassette(cassette)
+	return client
+}
+
+// EnableCache 开启响应缓存，按Cache-Control、Expires、ETag、Last-Modified缓存GET请求的响应
+func (client *AdvanceHttpClient) EnableCache(storage CacheStorage) *AdvanceHttpClient {
+	client.engine.enableCache(storage)
+	return client
 }
 
 func NewAdvanceSettings(rwTimeout time.Duration, retry int, retryInterval time.Duration, retryHttpStatuses ...int) *AdvanceSettings {
 	return &AdvanceSettings{
-		readWriteTimeout:  rwTimeout,
-		params:            url.Values{},
-		headers:           http.Header{},
-		baseAuth:          false,
-		gzip:              false,
-		retry:             retry,
-		retryInterval:     retryInterval,
-		retryHttpStatuses: retryHttpStatuses,
+		requestOptions: newRequestOptions(rwTimeout, retry, retryInterval, retryHttpStatuses),
 	}
 }
 
-func (setting *AdvanceSettings) urlString(scheme, host, path string) string {
-	urlPath := url.URL{
-		Scheme:   scheme,
-		Host:     host,
-		Path:     path,
-		RawQuery: setting.params.Encode(),
-	}
+// EnableGZip 保留兼容
+//
+// Deprecated: 响应会按Content-Encoding自动解压gzip、deflate、zstd，无需再调用
+func (setting *AdvanceSettings) EnableGZip(gzip bool) *AdvanceSettings {
+	return setting
+}
 
-	return urlPath.String()
+// SetRequestCompression 设置请求body的压缩方式(gzip、deflate、zstd)，body达到minSize字节才压缩
+func (setting *AdvanceSettings) SetRequestCompression(encoding string, minSize int) *AdvanceSettings {
+	setting.compress.encoding = encoding
+	setting.compress.minSize = minSize
+	return setting
 }
 
-func (setting *AdvanceSettings) EnableGZip(gzip bool) *AdvanceSettings {
-	setting.gzip = gzip
+// SetMaxDecompressedSize 设置响应解压后的最大字节数，防止解压炸弹，<=0不限制
+func (setting *AdvanceSettings) SetMaxDecompressedSize(size int64) *AdvanceSettings {
+	setting.compress.maxDecompressedSize = size
 	return setting
 }
 
@@ -184,18 +141,37 @@ func (setting *AdvanceSettings) SetHeaders(kvs map[string]string) *AdvanceSettin
 	return setting
 }
 
+// SetRedirectPolicy 设置跳转策略，默认不跟随跳转
+func (setting *AdvanceSettings) SetRedirectPolicy(policy RedirectPolicy) *AdvanceSettings {
+	setting.redirectPolicy = &policy
+	return setting
+}
+
+// SetHedgePolicy 设置对冲请求策略，仅对GET、HEAD请求生效
+// 请求在delay时间内未返回时再发出一个相同的请求，最多额外发出maxHedges个，取最先返回的响应
+func (setting *AdvanceSettings) SetHedgePolicy(delay time.Duration, maxHedges int) *AdvanceSettings {
+	setting.hedgeDelay = delay
+	setting.maxHedges = maxHedges
+	return setting
+}
+
 func (setting *AdvanceSettings) SetCookie(cookie *http.Cookie) *AdvanceSettings {
 	setting.cookie = cookie
 	return setting
 }
 
 func (setting *AdvanceSettings) SetBody(body io.Reader) *AdvanceSettings {
-	rawBody, _ := ioutil.ReadAll(body)
-	setting.body = bytes.NewBuffer(rawBody)
+	rawBody, _ := io.ReadAll(body)
 	setting.rawBody = rawBody
 	return setting
 }
 
+// SetAuthenticator 设置认证方式，如NewBearerAuth、NewClientCredentialsAuth、NewHMACAuth
+func (setting *AdvanceSettings) SetAuthenticator(auth Authenticator) *AdvanceSettings {
+	setting.auth = auth
+	return setting
+}
+
 func (setting *AdvanceSettings) SetBasicAuth(username, password string) *AdvanceSettings {
 	setting.baseAuth = true
 	setting.baseAuthUsername = username
@@ -203,92 +179,83 @@ func (setting *AdvanceSettings) SetBasicAuth(username, password string) *Advance
 	return setting
 }
 
-func (setting *AdvanceSettings) retryCheck(responseStatusCode int) bool {
-	for _, code := range setting.retryHttpStatuses {
-		if code == responseStatusCode {
-			return true
-		}
-	}
-
-	return false
-}
-
 func (client *AdvanceHttpClient) Get(uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	setting.body = nil
 	setting.rawBody = []byte{}
-	return client.do("GET", uri, setting)
+	return client.do(context.Background(), "GET", uri, setting)
 }
 
 func (client *AdvanceHttpClient) Post(uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	return client.do("POST", uri, setting)
+	return client.do(context.Background(), "POST", uri, setting)
 }
 
 func (client *AdvanceHttpClient) Put(uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	return client.do("PUT", uri, setting)
+	return client.do(context.Background(), "PUT", uri, setting)
 }
 
 func (client *AdvanceHttpClient) Delete(uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	return client.do("DELETE", uri, setting)
+	return client.do(context.Background(), "DELETE", uri, setting)
 }
 
 func (client *AdvanceHttpClient) Head(uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	return client.do("HEAD", uri, setting)
+	return client.do(context.Background(), "HEAD", uri, setting)
 }
 
-func (client *AdvanceHttpClient) Do(method, targetUrl string, setting *AdvanceSettings) (*AdvanceResponse, error) {
-	return client.do(method, targetUrl, setting)
+func (client *AdvanceHttpClient) Do(method, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(context.Background(), method, uri, setting)
 }
 
-func (client *AdvanceHttpClient) ToCurlCommand(method, targetUrl string, setting *AdvanceSettings) (string, error) {
-	req, err := genHttpRequest(method, targetUrl, setting)
-	if err != nil {
-		return "", err
-	}
+func (client *AdvanceHttpClient) GetWithContext(ctx context.Context, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	setting.rawBody = []byte{}
+	return client.do(ctx, "GET", uri, setting)
+}
 
-	cmd, err := http2curl.GetCurlCommand(req)
-	if err != nil {
-		return "", err
-	}
+func (client *AdvanceHttpClient) PostWithContext(ctx context.Context, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(ctx, "POST", uri, setting)
+}
 
-	return cmd.String(), nil
+func (client *AdvanceHttpClient) PutWithContext(ctx context.Context, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(ctx, "PUT", uri, setting)
 }
 
-func genHttpRequest(method, url string, setting *AdvanceSettings) (*http.Request, error) {
-	for _, code := range setting.retryHttpStatuses {
-		if code <= 201 {
-			return nil, fmt.Errorf("设置的重试http状态码包含201及以下, %+v", setting.retryHttpStatuses)
-		}
+func (client *AdvanceHttpClient) DeleteWithContext(ctx context.Context, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(ctx, "DELETE", uri, setting)
+}
 
-		if code >= 500 {
-			return nil, fmt.Errorf("设置的重试http状态码包含500及以上服务端错误的状态码, %+v", setting.retryHttpStatuses)
-		}
-	}
+func (client *AdvanceHttpClient) HeadWithContext(ctx context.Context, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(ctx, "HEAD", uri, setting)
+}
 
-	setting.body = bytes.NewBuffer(setting.rawBody)
+func (client *AdvanceHttpClient) DoWithContext(ctx context.Context, method, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	return client.do(ctx, method, uri, setting)
+}
 
-	req, err := http.NewRequest(method, url, setting.body)
+// Stream 发起流式请求，不读取body，适用于SSE、NDJSON等长连接响应
+// rwTimeout仅限制等待响应头的时间，返回的响应使用完毕后需调用Close
+func (client *AdvanceHttpClient) Stream(ctx context.Context, method, uri string, setting *AdvanceSettings) (*StreamResponse, error) {
+	u, err := client.parseUri(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	for key, values := range setting.headers {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
-	}
+	return client.engine.stream(ctx, method, *u, &setting.requestOptions)
+}
 
-	if setting.cookie != nil {
-		req.Header.Add("Cookie", setting.cookie.String())
+// EventSource 创建SSE客户端，使用setting当前的请求头、query参数及重试设置
+func (client *AdvanceHttpClient) EventSource(uri string, setting *AdvanceSettings) (*EventSource, error) {
+	u, err := client.parseUri(uri)
+	if err != nil {
+		return nil, err
 	}
 
-	if setting.baseAuth {
-		req.SetBasicAuth(setting.baseAuthUsername, setting.baseAuthPassword)
-	}
+	return newEventSource(client.engine, *u, &setting.requestOptions), nil
+}
 
-	return req, nil
+// HedgeStats 返回该client对冲请求的统计信息
+func (client *AdvanceHttpClient) HedgeStats() HedgeStats {
+	return client.engine.stats()
 }
 
-func (client *AdvanceHttpClient) do(method, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+func (client *AdvanceHttpClient) parseUri(uri string) (*url.URL, error) {
 	u, err := url.ParseRequestURI(uri)
 	if err != nil {
 		return nil, err
@@ -298,90 +265,27 @@ func (client *AdvanceHttpClient) do(method, uri string, setting *AdvanceSettings
 		return nil, fmt.Errorf("uri中不能存在query参数[%s]，请使用setting.SetParam等方法预设置", u.RawQuery)
 	}
 
-	url := setting.urlString(client.scheme, client.host, uri)
-
-	if setting.retry <= 0 {
-		setting.retry = 1
-	} else {
-		setting.retry++
-	}
-
-	adresp := &AdvanceResponse{}
-
-	startTime := time.Now()
-	for i := 0; i < setting.retry; i++ {
-		// solve Golang http post error : http: ContentLength=355 with Body length 0 bug
-		err := client.doOnce(method, url, setting, adresp)
-		if err != nil {
-			// 达到retry的次数
-			if i == setting.retry-1 {
-				return nil, err
-			}
-			time.Sleep(setting.retryInterval)
-			continue
-		}
-
-		if setting.retryCheck(adresp.StatusCode) {
-			if i == setting.retry-1 {
-				break
-			}
-
-			time.Sleep(setting.retryInterval)
-			continue
-		}
-
-		break
-	}
-
-	adresp.Time = int64(time.Now().Sub(startTime))
-
-	return adresp, nil
+	return &url.URL{
+		Scheme: client.scheme,
+		Host:   client.host,
+		Path:   uri,
+	}, nil
 }
 
-func (client *AdvanceHttpClient) doOnce(method, url string, setting *AdvanceSettings, adresp *AdvanceResponse) error {
-	req, err := genHttpRequest(method, url, setting)
-	if err != nil {
-		return err
-	}
-
-	if setting.readWriteTimeout > 0 {
-		ctx, cancel := context.WithTimeout(req.Context(), setting.readWriteTimeout)
-		defer cancel()
-		req = req.WithContext(ctx)
-	}
-
-	resp, err := client.client.Do(req)
-
+func (client *AdvanceHttpClient) ToCurlCommand(method, uri string, setting *AdvanceSettings) (string, error) {
+	u, err := client.parseUri(uri)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	defer resp.Body.Close()
-
-	adresp.Header = resp.Header
-	adresp.StatusCode = resp.StatusCode
-	adresp.Status = resp.Status
-
-	if setting.gzip && resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return err
-		}
-
-		body, err := ioutil.ReadAll(reader)
-		if err != nil {
-			return err
-		}
-
-		adresp.Body = body
-		return nil
-	}
+	return client.engine.curlCommand(context.Background(), method, *u, &setting.requestOptions)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+func (client *AdvanceHttpClient) do(ctx context.Context, method, uri string, setting *AdvanceSettings) (*AdvanceResponse, error) {
+	u, err := client.parseUri(uri)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	adresp.Body = body
-	return nil
+	return client.engine.do(ctx, method, *u, &setting.requestOptions)
 }
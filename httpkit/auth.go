@@ -0,0 +1,311 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator 为请求添加认证信息，在每次发出请求(包括重试、对冲)前调用
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// Refresher 可刷新凭证的Authenticator，请求返回401时刷新凭证并重试一次
+// req为返回401的请求
+type Refresher interface {
+	Refresh(ctx context.Context, req *http.Request) error
+}
+
+// BearerAuth 设置Authorization: Bearer <token>
+type BearerAuth struct {
+	token   string
+	tokenFn func(ctx context.Context) (string, error)
+}
+
+// NewBearerAuth 使用固定的token
+func NewBearerAuth(token string) *BearerAuth {
+	return &BearerAuth{token: token}
+}
+
+// NewBearerAuthFunc 每次请求时调用fn获取token
+func NewBearerAuthFunc(fn func(ctx context.Context) (string, error)) *BearerAuth {
+	return &BearerAuth{tokenFn: fn}
+}
+
+func (a *BearerAuth) Authenticate(req *http.Request) error {
+	token := a.token
+	if a.tokenFn != nil {
+		var err error
+		if token, err = a.tokenFn(req.Context()); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// ClientCredentialsConfig OAuth2 client credentials模式的配置
+type ClientCredentialsConfig struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values    // 获取token时额外的参数，如audience
+	AuthInParams   bool          // 将client_id、client_secret放在请求参数中，默认使用Basic认证
+	RefreshBefore  time.Duration // token过期前多久刷新，默认30s
+	Client         *http.Client  // 获取token使用的client，默认10s超时
+}
+
+// Token OAuth2 token
+type Token struct {
+	AccessToken string
+	TokenType   string
+	Expiry      time.Time // 零值表示不过期
+}
+
+// ClientCredentialsAuth 缓存token，过期前自动刷新，并发安全
+type ClientCredentialsAuth struct {
+	cfg ClientCredentialsConfig
+
+	lock  sync.Mutex
+	token *Token
+	now   func() time.Time
+}
+
+const defaultRefreshBefore = 30 * time.Second
+
+func NewClientCredentialsAuth(cfg ClientCredentialsConfig) (*ClientCredentialsAuth, error) {
+	if cfg.TokenURL == "" || cfg.ClientID == "" {
+		return nil, errors.New("oauth2 TokenURL、ClientID不能为空")
+	}
+
+	if cfg.RefreshBefore <= 0 {
+		cfg.RefreshBefore = defaultRefreshBefore
+	}
+
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &ClientCredentialsAuth{
+		cfg: cfg,
+		now: time.Now,
+	}, nil
+}
+
+func (a *ClientCredentialsAuth) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+// Token 返回缓存的token，不存在或即将过期时重新获取
+func (a *ClientCredentialsAuth) Token(ctx context.Context) (*Token, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token != nil && (a.token.Expiry.IsZero() || a.now().Add(a.cfg.RefreshBefore).Before(a.token.Expiry)) {
+		return a.token, nil
+	}
+
+	return a.fetch(ctx)
+}
+
+// Refresh 请求返回401时重新获取token，token已被其他请求刷新时不再重复获取
+func (a *ClientCredentialsAuth) Refresh(ctx context.Context, req *http.Request) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token != nil && req.Header.Get("Authorization") != "Bearer "+a.token.AccessToken {
+		return nil
+	}
+
+	_, err := a.fetch(ctx)
+	return err
+}
+
+func (a *ClientCredentialsAuth) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	for key, values := range a.cfg.EndpointParams {
+		form[key] = append([]string(nil), values...)
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(a.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.cfg.Scopes, " "))
+	}
+	if a.cfg.AuthInParams {
+		form.Set("client_id", a.cfg.ClientID)
+		form.Set("client_secret", a.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !a.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+	}
+
+	resp, err := a.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2获取token失败, status: %s, body: %s", resp.Status, body)
+	}
+
+	var tr struct {
+		AccessToken string      `json:"access_token"`
+		TokenType   string      `json:"token_type"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("oauth2 token解析失败: %w", err)
+	}
+
+	if tr.AccessToken == "" {
+		return nil, errors.New("oauth2 token响应中缺少access_token")
+	}
+
+	token := &Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+	}
+	if expiresIn, _ := strconv.ParseInt(tr.ExpiresIn.String(), 10, 64); expiresIn > 0 {
+		token.Expiry = a.now().Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	a.token = token
+	return token, nil
+}
+
+// HMACConfig HMAC签名的配置
+type HMACConfig struct {
+	KeyID           string
+	Secret          []byte
+	Hash            func() hash.Hash // 默认sha256
+	TimestampHeader string           // 默认X-Timestamp
+	DigestHeader    string           // body哈希的请求头，默认X-Content-SHA256
+}
+
+// HMACAuth 对请求签名，签名内容依次为method、path、排序后的query、body哈希、时间戳，以换行分隔
+// 签名写入Authorization: HMAC keyId=<KeyID>,signature=<base64签名>
+type HMACAuth struct {
+	cfg HMACConfig
+	now func() time.Time
+}
+
+func NewHMACAuth(cfg HMACConfig) *HMACAuth {
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+
+	if cfg.TimestampHeader == "" {
+		cfg.TimestampHeader = "X-Timestamp"
+	}
+
+	if cfg.DigestHeader == "" {
+		cfg.DigestHeader = "X-Content-SHA256"
+	}
+
+	return &HMACAuth{
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+func (a *HMACAuth) Authenticate(req *http.Request) error {
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		body, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	timestamp := strconv.FormatInt(a.now().Unix(), 10)
+	digest := sha256.Sum256(body)
+
+	req.Header.Set(a.cfg.TimestampHeader, timestamp)
+	req.Header.Set(a.cfg.DigestHeader, hex.EncodeToString(digest[:]))
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC keyId=%s,signature=%s",
+		a.cfg.KeyID, a.Sign(req.Method, req.URL.EscapedPath(), req.URL.Query(), body, timestamp)))
+
+	return nil
+}
+
+// Sign 计算签名，服务端可使用相同的参数校验签名
+func (a *HMACAuth) Sign(method, path string, query url.Values, body []byte, timestamp string) string {
+	digest := sha256.Sum256(body)
+
+	mac := hmac.New(a.cfg.Hash, a.cfg.Secret)
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		canonicalQuery(query),
+		hex.EncodeToString(digest[:]),
+		timestamp,
+	}, "\n")))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalQuery key、value均按字典序排序后编码
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			if buf.Len() > 0 {
+				buf.WriteByte('&')
+			}
+			buf.WriteString(url.QueryEscape(key))
+			buf.WriteByte('=')
+			buf.WriteString(url.QueryEscape(value))
+		}
+	}
+
+	return buf.String()
+}
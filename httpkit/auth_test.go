@@ -0,0 +1,154 @@
+package httpkit
+
+import (
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBearerAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil).SetAuthenticator(NewBearerAuth("abc"))
+	resp, err := client.Get(srv.URL)
+	if err != nil || string(resp.Body) != "Bearer abc" {
+		t.Fatalf("unexpected response %v %+v", err, resp)
+	}
+}
+
+func TestClientCredentialsAuth(t *testing.T) {
+	var fetches atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		r.ParseForm()
+		if !ok || user != "id" || pass != "secret" ||
+			r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "read write" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		n := fetches.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenSrv.Close()
+
+	// 只接受最新的token
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", fetches.Load()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	auth, err := NewClientCredentialsAuth(ClientCredentialsConfig{
+		TokenURL:     tokenSrv.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+	if err != nil {
+		t.Fatalf("create auth failed, %v", err)
+	}
+
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	setting := NewAdvanceSettings(time.Second, 0, 0).SetAuthenticator(auth)
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Post("/", setting.SetBody(strings.NewReader("ping")))
+		if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "ping" {
+			t.Fatalf("unexpected response %v %+v", err, resp)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("token should be cached, fetched %d times", n)
+	}
+
+	// 过期前RefreshBefore时间内刷新
+	now = now.Add(3600*time.Second - defaultRefreshBefore + time.Second)
+	if _, err := client.Get("/", setting); err != nil {
+		t.Fatalf("get failed, %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("token should be refreshed before expiry, fetched %d times", n)
+	}
+
+	// 服务端吊销token后，401时刷新并重试一次，body需要重放
+	fetches.Add(1)
+	resp, err := client.Post("/", setting.SetBody(strings.NewReader("again")))
+	if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "again" {
+		t.Fatalf("expected retry after refresh, %v %+v", err, resp)
+	}
+	if n := fetches.Load(); n != 4 {
+		t.Fatalf("expected one refresh on 401, got %d fetches", n)
+	}
+}
+
+func TestClientCredentialsAuthError(t *testing.T) {
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_client", http.StatusUnauthorized)
+	}))
+	defer tokenSrv.Close()
+
+	auth, _ := NewClientCredentialsAuth(ClientCredentialsConfig{TokenURL: tokenSrv.URL, ClientID: "id", AuthInParams: true})
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil).SetAuthenticator(auth)
+	if _, err := client.Get(tokenSrv.URL); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected token error, got %v", err)
+	}
+
+	if _, err := NewClientCredentialsAuth(ClientCredentialsConfig{}); err == nil {
+		t.Fatalf("empty config should fail")
+	}
+}
+
+func TestHMACAuth(t *testing.T) {
+	verifier := NewHMACAuth(HMACConfig{KeyID: "k1", Secret: []byte("s3cret")})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := "HMAC keyId=k1,signature=" + verifier.Sign(r.Method, r.URL.EscapedPath(), r.URL.Query(), body, r.Header.Get("X-Timestamp"))
+		if !hmac.Equal([]byte(r.Header.Get("Authorization")), []byte(expected)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Timestamp")))
+	}))
+	defer srv.Close()
+
+	auth := NewHMACAuth(HMACConfig{KeyID: "k1", Secret: []byte("s3cret")})
+	auth.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil).
+		SetAuthenticator(auth).
+		AddParam("b", "2").AddParam("a", "z").AddParam("a", "y").
+		SetBody(strings.NewReader(`{"k":"v"}`))
+	resp, err := client.Post(srv.URL + "/sign/path")
+	if err != nil || resp.StatusCode != http.StatusOK || string(resp.Body) != "1700000000" {
+		t.Fatalf("unexpected response %v %+v", err, resp)
+	}
+
+	if q := canonicalQuery(url.Values{"b": {"2"}, "a": {"z", "y"}, "c d": {"&"}}); q != "a=y&a=z&b=2&c+d=%26" {
+		t.Fatalf("unexpected canonical query %q", q)
+	}
+
+	other := NewHMACAuth(HMACConfig{KeyID: "k1", Secret: []byte("other")})
+	resp, err = client.SetAuthenticator(other).Post(srv.URL + "/sign/path")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong secret should be rejected, %v %+v", err, resp)
+	}
+}
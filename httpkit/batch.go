@@ -0,0 +1,248 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchConcurrency 未设置并发数时批量请求的并发数
+const DefaultBatchConcurrency = 10
+
+// BatchRequest 批量请求中的单个请求
+type BatchRequest struct {
+	Method  string
+	Uri     string
+	Setting *AdvanceSettings // 为nil时使用BatchOptions.Setting
+}
+
+// BatchResult 单个请求的结果，Index为请求在批量请求中的下标
+type BatchResult struct {
+	Index    int
+	Request  BatchRequest
+	Response *AdvanceResponse
+	Err      error
+	Shared   bool // 与其他相同的GET请求合并，共用同一个响应
+}
+
+// BatchOptions 批量请求的设置
+type BatchOptions struct {
+	Concurrency  int              // 最大并发数，默认DefaultBatchConcurrency
+	Timeout      time.Duration    // 所有请求共享的截止时间，<=0不限制
+	Setting      *AdvanceSettings // 请求未设置Setting时使用
+	DisableDedup bool             // 不合并同时进行的相同GET请求
+}
+
+// BatchError 批量请求中部分请求失败
+type BatchError struct {
+	Total  int
+	Failed []BatchResult
+}
+
+func (e *BatchError) Error() string {
+	first := e.Failed[0]
+	return fmt.Sprintf("批量请求%d个中有%d个失败, 首个错误: [%d] %s %s: %v",
+		e.Total, len(e.Failed), first.Index, first.Request.Method, first.Request.Uri, first.Err)
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		errs = append(errs, r.Err)
+	}
+	return errs
+}
+
+// Batch 并发执行reqs，按请求顺序返回结果，部分请求失败时返回*BatchError
+// 返回的错误不包含http状态码，需自行检查Response.StatusCode
+func (client *AdvanceHttpClient) Batch(ctx context.Context, reqs []BatchRequest, opts BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(reqs))
+	for r := range client.BatchStream(ctx, reqs, opts) {
+		results[r.Index] = r
+	}
+
+	batchErr := &BatchError{Total: len(reqs)}
+	for _, r := range results {
+		if r.Err != nil {
+			batchErr.Failed = append(batchErr.Failed, r)
+		}
+	}
+
+	if len(batchErr.Failed) > 0 {
+		return results, batchErr
+	}
+
+	return results, nil
+}
+
+// BatchStream 并发执行reqs，按完成顺序返回结果，全部完成后关闭channel
+func (client *AdvanceHttpClient) BatchStream(ctx context.Context, reqs []BatchRequest, opts BatchOptions) <-chan BatchResult {
+	results := make(chan BatchResult, len(reqs))
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	go func() {
+		defer close(results)
+
+		ctx := ctx
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		group := &flightGroup{}
+		sem := make(chan struct{}, concurrency)
+
+		var wg sync.WaitGroup
+		for i, req := range reqs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results <- BatchResult{Index: i, Request: req, Err: ctx.Err()}
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, req BatchRequest) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				results <- client.batchDo(ctx, group, i, req, opts)
+			}(i, req)
+		}
+
+		wg.Wait()
+	}()
+
+	return results
+}
+
+func (client *AdvanceHttpClient) batchDo(ctx context.Context, group *flightGroup, index int, req BatchRequest, opts BatchOptions) BatchResult {
+	result := BatchResult{Index: index, Request: req}
+
+	setting := req.Setting
+	if setting == nil {
+		setting = opts.Setting
+	}
+	if setting == nil {
+		result.Err = errors.New("批量请求未设置AdvanceSettings")
+		return result
+	}
+
+	if ctx.Err() != nil {
+		result.Err = ctx.Err()
+		return result
+	}
+
+	if opts.DisableDedup || req.Method != http.MethodGet {
+		result.Response, result.Err = client.do(ctx, req.Method, req.Uri, setting)
+		return result
+	}
+
+	key, err := client.flightKey(req.Method, req.Uri, setting)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Response, result.Shared, result.Err = group.do(key, func() (*AdvanceResponse, error) {
+		return client.do(ctx, req.Method, req.Uri, setting)
+	})
+
+	return result
+}
+
+// flightKey 相同的method、url、请求头、cookie及认证方式视为相同的请求
+func (client *AdvanceHttpClient) flightKey(method, uri string, setting *AdvanceSettings) (string, error) {
+	u, err := client.parseUri(uri)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = setting.params.Encode()
+
+	var buf strings.Builder
+	buf.WriteString(method)
+	buf.WriteByte(' ')
+	buf.WriteString(u.String())
+
+	keys := make([]string, 0, len(setting.headers))
+	for key := range setting.headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "\n%s: %s", key, strings.Join(setting.headers[key], ","))
+	}
+
+	if setting.cookie != nil {
+		fmt.Fprintf(&buf, "\ncookie: %s", setting.cookie.String())
+	}
+	if setting.baseAuth {
+		fmt.Fprintf(&buf, "\nbasic: %s:%s", setting.baseAuthUsername, setting.baseAuthPassword)
+	}
+	if setting.auth != nil {
+		fmt.Fprintf(&buf, "\nauth: %p", setting.auth)
+	}
+
+	return buf.String(), nil
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	resp *AdvanceResponse
+	err  error
+}
+
+// flightGroup 合并同时进行的相同请求，只发出一次
+type flightGroup struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+func (g *flightGroup) do(key string, fn func() (*AdvanceResponse, error)) (*AdvanceResponse, bool, error) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if call, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		call.wg.Wait()
+		return copyResponse(call.resp), true, call.err
+	}
+
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.lock.Unlock()
+
+	call.resp, call.err = fn()
+	call.wg.Done()
+
+	g.lock.Lock()
+	delete(g.calls, key)
+	g.lock.Unlock()
+
+	return copyResponse(call.resp), false, call.err
+}
+
+// copyResponse 合并的请求各自持有一份响应，Body、Header不共享
+func copyResponse(resp *AdvanceResponse) *AdvanceResponse {
+	if resp == nil {
+		return nil
+	}
+
+	c := *resp
+	c.Body = append([]byte(nil), resp.Body...)
+	c.Header = resp.Header.Clone()
+	return &c
+}
@@ -0,0 +1,124 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdvanceHttpClientBatch(t *testing.T) {
+	var active, maxActive, calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+
+	// 相同的GET请求会被合并
+	var reqs []BatchRequest
+	shared := NewAdvanceSettings(time.Second, 0, 0).SetParam("id", "dup")
+	for i := 0; i < 3; i++ {
+		reqs = append(reqs, BatchRequest{Method: http.MethodGet, Uri: "/users", Setting: shared})
+	}
+	for i := 3; i < 11; i++ {
+		reqs = append(reqs, BatchRequest{
+			Method:  http.MethodGet,
+			Uri:     "/users",
+			Setting: NewAdvanceSettings(time.Second, 0, 0).SetParam("id", fmt.Sprint(i)),
+		})
+	}
+	// 无效请求只影响自身
+	reqs = append(reqs, BatchRequest{Method: http.MethodGet, Uri: "/users?id=bad"})
+
+	results, err := client.Batch(context.Background(), reqs, BatchOptions{
+		Concurrency: 4,
+		Setting:     NewAdvanceSettings(time.Second, 0, 0),
+	})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Total != len(reqs) || len(batchErr.Failed) != 1 || batchErr.Failed[0].Index != 11 {
+		t.Fatalf("expected partial failure for index 11, got %v", err)
+	}
+
+	for i := 3; i < 11; i++ {
+		if results[i].Index != i || string(results[i].Response.Body) != fmt.Sprintf("/users?id=%d", i) {
+			t.Fatalf("result %d out of order, %+v", i, results[i])
+		}
+	}
+
+	sharedCount := 0
+	for _, r := range results[:3] {
+		if r.Err != nil || string(r.Response.Body) != "/users?id=dup" {
+			t.Fatalf("unexpected dedup result %+v", r)
+		}
+		if r.Shared {
+			sharedCount++
+		}
+	}
+
+	if max := maxActive.Load(); max > 4 {
+		t.Fatalf("concurrency limit exceeded, %d", max)
+	}
+
+	if n := int(calls.Load()); n != 8+3-sharedCount || sharedCount == 0 {
+		t.Fatalf("expected duplicate GETs to be collapsed, calls %d shared %d", n, sharedCount)
+	}
+}
+
+func TestAdvanceHttpClientBatchDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	setting := NewAdvanceSettings(5*time.Second, 0, 0)
+
+	reqs := []BatchRequest{
+		{Method: http.MethodGet, Uri: "/fast"},
+		{Method: http.MethodPost, Uri: "/slow"},
+		{Method: http.MethodPost, Uri: "/slow"},
+		{Method: http.MethodGet, Uri: "/fast"},
+	}
+
+	start := time.Now()
+	var completed []int
+	for r := range client.BatchStream(context.Background(), reqs, BatchOptions{Concurrency: 2, Timeout: 100 * time.Millisecond, Setting: setting}) {
+		completed = append(completed, r.Index)
+		if r.Request.Uri == "/slow" && !errors.Is(r.Err, context.DeadlineExceeded) {
+			t.Fatalf("slow request should hit shared deadline, got %v", r.Err)
+		}
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("batch should stop at deadline, took %v", time.Since(start))
+	}
+
+	if len(completed) != len(reqs) || completed[0] != 0 {
+		t.Fatalf("results should arrive as completed, %v", completed)
+	}
+}
@@ -0,0 +1,284 @@
+package httpkit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStatus 响应的缓存状态，未开启缓存时为空
+type CacheStatus string
+
+const (
+	CacheMiss        CacheStatus = "MISS"        // 未命中缓存，响应来自服务端
+	CacheHit         CacheStatus = "HIT"         // 命中新鲜的缓存，未访问服务端
+	CacheRevalidated CacheStatus = "REVALIDATED" // 缓存过期，经服务端304校验后使用缓存
+)
+
+// cacheStatusHeader 缓存层通过该响应头将缓存状态传递给client，读取后会被删除
+const cacheStatusHeader = "X-Httpkit-Cache"
+
+// CacheEntry 缓存的一个响应
+type CacheEntry struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Vary       http.Header // Vary中列出的请求头及其取值
+	StoredAt   time.Time
+	Expires    time.Time
+}
+
+// CacheStorage 缓存的存储，需并发安全
+type CacheStorage interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// cacheTransport 按RFC 7234实现的私有缓存，仅缓存GET请求
+type cacheTransport struct {
+	transport http.RoundTripper
+	storage   CacheStorage
+}
+
+func newCacheTransport(transport http.RoundTripper, storage CacheStorage) *cacheTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &cacheTransport{
+		transport: transport,
+		storage:   storage,
+	}
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.transport.RoundTrip(req)
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.transport.RoundTrip(req)
+	}
+
+	key := req.Method + " " + req.URL.String()
+
+	entry, ok := t.storage.Get(key)
+	if ok && !entry.varyMatches(req) {
+		ok = false
+	}
+
+	revalidating := false
+	if ok {
+		_, noCache := reqCC["no-cache"]
+		if !noCache && time.Now().Before(entry.Expires) {
+			return entry.response(req, CacheHit), nil
+		}
+
+		etag := entry.Header.Get("ETag")
+		lastModified := entry.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+			revalidating = true
+		}
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if revalidating && resp.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		updated := entry.revalidate(resp.Header, now)
+		t.storage.Set(key, updated)
+		return updated.response(req, CacheRevalidated), nil
+	}
+
+	if !storable(resp) {
+		if ok {
+			t.storage.Delete(key)
+		}
+		resp.Header.Set(cacheStatusHeader, string(CacheMiss))
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	t.storage.Set(key, &CacheEntry{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Vary:       varyHeaders(req, resp.Header),
+		StoredAt:   now,
+		Expires:    now.Add(freshnessLifetime(resp.Header, now)),
+	})
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set(cacheStatusHeader, string(CacheMiss))
+	return resp, nil
+}
+
+func (entry *CacheEntry) response(req *http.Request, status CacheStatus) *http.Response {
+	header := entry.Header.Clone()
+	header.Set(cacheStatusHeader, string(status))
+	header.Set("Age", strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+
+	return &http.Response{
+		Status:        entry.Status,
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// revalidate 根据304响应更新缓存的响应头和过期时间，返回新的CacheEntry
+func (entry *CacheEntry) revalidate(header http.Header, now time.Time) *CacheEntry {
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for key, values := range header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Transfer-Encoding", "Connection":
+			continue
+		}
+		updated.Header[key] = values
+	}
+
+	updated.StoredAt = now
+	updated.Expires = now.Add(freshnessLifetime(updated.Header, now))
+	return &updated
+}
+
+func (entry *CacheEntry) varyMatches(req *http.Request) bool {
+	for key, values := range entry.Vary {
+		if strings.Join(req.Header.Values(key), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func varyHeaders(req *http.Request, header http.Header) http.Header {
+	vary := http.Header{}
+	for _, v := range header.Values("Vary") {
+		for _, key := range strings.Split(v, ",") {
+			key = strings.TrimSpace(key)
+			if key != "" {
+				vary[http.CanonicalHeaderKey(key)] = req.Header.Values(key)
+			}
+		}
+	}
+
+	return vary
+}
+
+func storable(resp *http.Response) bool {
+	if !cacheableStatuses[resp.StatusCode] {
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	for _, v := range resp.Header.Values("Vary") {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+
+	if resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" {
+		return true
+	}
+
+	return freshnessLifetime(resp.Header, time.Now()) > 0
+}
+
+// freshnessLifetime 计算响应剩余的新鲜时间，不做启发式推算
+func freshnessLifetime(header http.Header, now time.Time) time.Duration {
+	cc := parseCacheControl(header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+
+	var lifetime time.Duration
+	if v, ok := cc["max-age"]; ok {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+
+		date := now
+		if d, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = d
+		}
+		lifetime = expires.Sub(date)
+	} else {
+		return 0
+	}
+
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+
+	return lifetime
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+
+			key, value, _ := strings.Cut(directive, "=")
+			cc[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return cc
+}
@@ -0,0 +1,174 @@
+package httpkit
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryCache 按字节数限制容量的LRU内存缓存
+type MemoryCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	curBytes int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+	size  int64
+}
+
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (cache *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	elem, ok := cache.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	cache.ll.MoveToFront(elem)
+	return elem.Value.(*memoryCacheItem).entry, true
+}
+
+func (cache *MemoryCache) Set(key string, entry *CacheEntry) {
+	size := entrySize(key, entry)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		cache.removeElement(elem)
+	}
+
+	// 单个响应超过容量时不缓存
+	if cache.maxBytes > 0 && size > cache.maxBytes {
+		return
+	}
+
+	elem := cache.ll.PushFront(&memoryCacheItem{key: key, entry: entry, size: size})
+	cache.items[key] = elem
+	cache.curBytes += size
+
+	for cache.maxBytes > 0 && cache.curBytes > cache.maxBytes {
+		cache.removeElement(cache.ll.Back())
+	}
+}
+
+func (cache *MemoryCache) Delete(key string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		cache.removeElement(elem)
+	}
+}
+
+// Size 返回当前缓存占用的字节数
+func (cache *MemoryCache) Size() int64 {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return cache.curBytes
+}
+
+func (cache *MemoryCache) removeElement(elem *list.Element) {
+	item := cache.ll.Remove(elem).(*memoryCacheItem)
+	delete(cache.items, item.key)
+	cache.curBytes -= item.size
+}
+
+func entrySize(key string, entry *CacheEntry) int64 {
+	size := len(key) + len(entry.Body) + len(entry.Status)
+	for k, values := range entry.Header {
+		size += len(k)
+		for _, v := range values {
+			size += len(v)
+		}
+	}
+
+	return int64(size)
+}
+
+// DiskCache 以文件形式存储缓存，每个key对应dir下的一个文件
+type DiskCache struct {
+	lock sync.RWMutex
+	dir  string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+func (cache *DiskCache) Get(key string) (*CacheEntry, bool) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+
+	f, err := os.Open(cache.path(key))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	entry := &CacheEntry{}
+	if err := gob.NewDecoder(f).Decode(entry); err != nil {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (cache *DiskCache) Set(key string, entry *CacheEntry) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	f, err := os.CreateTemp(cache.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+
+	err = gob.NewEncoder(f).Encode(entry)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	// 先写临时文件再rename，避免读到写了一半的文件
+	if err := os.Rename(f.Name(), cache.path(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (cache *DiskCache) Delete(key string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	os.Remove(cache.path(key))
+}
+
+func (cache *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.dir, hex.EncodeToString(sum[:]))
+}
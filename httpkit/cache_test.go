@@ -0,0 +1,120 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClientCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		statuses []CacheStatus
+		calls    int32
+	}{
+		{"/max-age", []CacheStatus{CacheMiss, CacheHit, CacheHit}, 1},
+		{"/etag", []CacheStatus{CacheMiss, CacheRevalidated, CacheRevalidated}, 3},
+		{"/no-store", []CacheStatus{CacheMiss, CacheMiss}, 2},
+	}
+
+	for _, tt := range tests {
+		calls.Store(0)
+		client := NewHttpClient(time.Second, 0, 0, time.Second, nil).EnableCache(NewMemoryCache(1 << 20))
+		for i, want := range tt.statuses {
+			resp, err := client.Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatalf("%s: get failed, %v", tt.path, err)
+			}
+
+			if resp.CacheStatus != want {
+				t.Fatalf("%s: request %d expected %s, got %s", tt.path, i, want, resp.CacheStatus)
+			}
+
+			if resp.StatusCode != http.StatusOK || string(resp.Body) != tt.path {
+				t.Fatalf("%s: unexpected response %d %q", tt.path, resp.StatusCode, resp.Body)
+			}
+
+			if resp.Header.Get(cacheStatusHeader) != "" {
+				t.Fatalf("%s: internal cache header leaked", tt.path)
+			}
+		}
+
+		if calls.Load() != tt.calls {
+			t.Fatalf("%s: expected %d server calls, got %d", tt.path, tt.calls, calls.Load())
+		}
+	}
+}
+
+func TestAdvanceHttpClientDiskCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		w.Write([]byte("disk"))
+	}))
+	defer srv.Close()
+
+	storage, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("new disk cache failed, %v", err)
+	}
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil).EnableCache(storage)
+	for _, want := range []CacheStatus{CacheMiss, CacheHit} {
+		resp, err := client.Get("/disk", NewAdvanceSettings(time.Second, 0, 0))
+		if err != nil {
+			t.Fatalf("get failed, %v", err)
+		}
+
+		if resp.CacheStatus != want || string(resp.Body) != "disk" {
+			t.Fatalf("expected %s disk, got %s %q", want, resp.CacheStatus, resp.Body)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 server call, got %d", calls.Load())
+	}
+}
+
+func TestMemoryCacheEvict(t *testing.T) {
+	cache := NewMemoryCache(100)
+	cache.Set("a", &CacheEntry{Body: make([]byte, 40)})
+	cache.Set("b", &CacheEntry{Body: make([]byte, 40)})
+	cache.Get("a")
+	cache.Set("c", &CacheEntry{Body: make([]byte, 40)})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Fatalf("least recently used entry should be evicted")
+	}
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("recently used entry should be kept")
+	}
+
+	if cache.Size() > 100 {
+		t.Fatalf("cache size %d exceeds budget", cache.Size())
+	}
+}
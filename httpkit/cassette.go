@@ -0,0 +1,353 @@
+package httpkit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode 录制回放模式
+type CassetteMode int
+
+const (
+	CassetteRecord CassetteMode = iota // 访问网络并把请求响应记录到文件
+	CassetteReplay                     // 只从文件回放，不访问网络
+	CassetteAuto                       // 文件存在时回放，不存在时录制
+)
+
+// CassetteMatch 回放时请求匹配的规则，可组合使用
+type CassetteMatch int
+
+const (
+	MatchMethod CassetteMatch = 1 << iota // 请求方法
+	MatchURL                              // scheme、host、path，不含query
+	MatchQuery                            // 排序后的query参数
+	MatchBody                             // 请求body
+
+	DefaultCassetteMatch = MatchMethod | MatchURL | MatchQuery
+)
+
+const redactedValue = "[REDACTED]"
+
+var ErrCassetteNoMatch = errors.New("httpkit: cassette中没有匹配的请求")
+
+// CassetteOptions 录制回放的设置
+type CassetteOptions struct {
+	Mode          CassetteMode
+	Match         CassetteMatch // 为0时使用DefaultCassetteMatch
+	RedactHeaders []string      // 写入文件前脱敏的请求头和响应头，为空时使用Authorization、Proxy-Authorization
+}
+
+// Interaction 一次录制的请求和响应
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Status       string      `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Cassette 以transport的方式录制回放http请求，通过client.EnableCassette启用
+type Cassette struct {
+	lock         sync.Mutex
+	path         string
+	mode         CassetteMode
+	match        CassetteMatch
+	redact       []string
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewCassette 打开cassette文件，回放模式下文件必须存在
+func NewCassette(path string, opts CassetteOptions) (*Cassette, error) {
+	cassette := &Cassette{
+		path:   path,
+		mode:   opts.Mode,
+		match:  opts.Match,
+		redact: opts.RedactHeaders,
+	}
+
+	if cassette.match == 0 {
+		cassette.match = DefaultCassetteMatch
+	}
+
+	if len(cassette.redact) == 0 {
+		cassette.redact = []string{"Authorization", "Proxy-Authorization"}
+	}
+
+	if cassette.mode == CassetteAuto {
+		cassette.mode = CassetteRecord
+		if fileExist(path) {
+			cassette.mode = CassetteReplay
+		}
+	}
+
+	if cassette.mode == CassetteReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &cassette.interactions); err != nil {
+			return nil, fmt.Errorf("cassette文件[%s]格式错误: %v", path, err)
+		}
+		cassette.used = make([]bool, len(cassette.interactions))
+	}
+
+	return cassette, nil
+}
+
+// Mode 返回实际使用的模式，CassetteAuto会被解析为录制或回放
+func (cassette *Cassette) Mode() CassetteMode {
+	return cassette.mode
+}
+
+// Transport 返回录制回放的RoundTripper，录制时使用next访问网络，next为nil时使用http.DefaultTransport
+func (cassette *Cassette) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &cassetteTransport{cassette: cassette, transport: next}
+}
+
+type cassetteTransport struct {
+	cassette  *Cassette
+	transport http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, outReq, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cassette.mode == CassetteReplay {
+		// 回放时请求不会经过内层transport，需要在这里关闭body
+		if outReq.Body != nil {
+			outReq.Body.Close()
+		}
+
+		interaction, err := t.cassette.find(req, body)
+		if err != nil {
+			return nil, err
+		}
+		return interaction.Response.response(req)
+	}
+
+	resp, err := t.transport.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	if err := t.cassette.record(req, body, resp, respBody); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (cassette *Cassette) find(req *http.Request, body []byte) (*Interaction, error) {
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+
+	for i, interaction := range cassette.interactions {
+		if !cassette.used[i] && cassette.matches(&interaction.Request, req, body) {
+			cassette.used[i] = true
+			return interaction, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrCassetteNoMatch, req.Method, req.URL.String())
+}
+
+func (cassette *Cassette) matches(recorded *CassetteRequest, req *http.Request, body []byte) bool {
+	if cassette.match&MatchMethod != 0 && recorded.Method != req.Method {
+		return false
+	}
+
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	if cassette.match&MatchURL != 0 &&
+		(u.Scheme != req.URL.Scheme || u.Host != req.URL.Host || u.Path != req.URL.Path) {
+		return false
+	}
+
+	if cassette.match&MatchQuery != 0 && u.Query().Encode() != req.URL.Query().Encode() {
+		return false
+	}
+
+	if cassette.match&MatchBody != 0 {
+		recordedBody, err := decodeCassetteBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil || !bytes.Equal(recordedBody, body) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (cassette *Cassette) record(req *http.Request, body []byte, resp *http.Response, respBody []byte) error {
+	interaction := &Interaction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: cassette.redactHeader(req.Header),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     cassette.redactHeader(resp.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeCassetteBody(body)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeCassetteBody(respBody)
+
+	cassette.lock.Lock()
+	defer cassette.lock.Unlock()
+
+	cassette.interactions = append(cassette.interactions, interaction)
+	return cassette.save()
+}
+
+// save 每次录制后重写整个文件，先写临时文件再rename
+func (cassette *Cassette) save() error {
+	data, err := json.MarshalIndent(cassette.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cassette.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := cassette.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, cassette.path)
+}
+
+func (cassette *Cassette) redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range cassette.redact {
+		if _, ok := header[http.CanonicalHeaderKey(key)]; ok {
+			header.Set(key, redactedValue)
+		}
+	}
+
+	return header
+}
+
+func (recorded *CassetteResponse) response(req *http.Request) (*http.Response, error) {
+	body, err := decodeCassetteBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        recorded.Status,
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody 读取请求body，优先使用GetBody以免消耗原body，
+// 否则读取并关闭原body，返回带有body副本的请求用于转发，不修改调用方的req，出错时关闭原body
+func readRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			req.Body.Close()
+			return nil, nil, err
+		}
+		defer rc.Close()
+
+		body, err := io.ReadAll(rc)
+		if err != nil {
+			req.Body.Close()
+			return nil, nil, err
+		}
+		return body, req, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outReq := req.Clone(req.Context())
+	outReq.Body = io.NopCloser(bytes.NewReader(body))
+	outReq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return body, outReq, nil
+}
+
+// encodeCassetteBody 文本body原样保存便于阅读，二进制body使用base64
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return []byte(body), nil
+}
+
+func fileExist(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,182 @@
+package httpkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Authorization", "server-secret")
+		w.Write([]byte(r.Method + " " + r.URL.RawQuery + " " + string(body)))
+	}))
+
+	path := filepath.Join(t.TempDir(), "cassettes", "api.json")
+
+	recorder, err := NewCassette(path, CassetteOptions{Mode: CassetteAuto, Match: DefaultCassetteMatch | MatchBody})
+	if err != nil {
+		t.Fatalf("new cassette failed, %v", err)
+	}
+
+	if recorder.Mode() != CassetteRecord {
+		t.Fatalf("auto mode without file should record")
+	}
+
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil).EnableCassette(recorder)
+	client.SetBasicAuth("user", "secret").SetParam("b", "2").SetParam("a", "1").SetBody(strings.NewReader("one"))
+	if _, err := client.Post(srv.URL + "/api"); err != nil {
+		t.Fatalf("record failed, %v", err)
+	}
+
+	client.SetBody(strings.NewReader("two"))
+	if _, err := client.Post(srv.URL + "/api"); err != nil {
+		t.Fatalf("record failed, %v", err)
+	}
+
+	srv.Close()
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Fatalf("authorization should be redacted, %s", data)
+	}
+
+	player, err := NewCassette(path, CassetteOptions{Mode: CassetteAuto, Match: DefaultCassetteMatch | MatchBody})
+	if err != nil {
+		t.Fatalf("open cassette failed, %v", err)
+	}
+
+	if player.Mode() != CassetteReplay {
+		t.Fatalf("auto mode with file should replay")
+	}
+
+	u := strings.TrimPrefix(srv.URL, "http://")
+	advance := NewAdvanceHttpClient("http", u, time.Second, nil).EnableCassette(player)
+
+	// 回放时query按排序后匹配，body顺序与录制时相反
+	for _, body := range []string{"two", "one"} {
+		setting := NewAdvanceSettings(time.Second, 0, 0).SetParam("a", "1").SetParam("b", "2").SetBody(strings.NewReader(body))
+		resp, err := advance.Post("/api", setting)
+		if err != nil {
+			t.Fatalf("replay %s failed, %v", body, err)
+		}
+
+		if string(resp.Body) != "POST a=1&b=2 "+body {
+			t.Fatalf("unexpected replay body %q", resp.Body)
+		}
+	}
+
+	setting := NewAdvanceSettings(time.Second, 0, 0).SetParam("a", "1").SetParam("b", "2").SetBody(strings.NewReader("one"))
+	if _, err := advance.Post("/api", setting); err == nil {
+		t.Fatalf("each recorded interaction should be replayed once")
+	}
+
+	if _, err := NewCassette(filepath.Join(t.TempDir(), "missing.json"), CassetteOptions{Mode: CassetteReplay}); err == nil {
+		t.Fatalf("replay without cassette file should fail")
+	}
+}
+
+func TestCassetteDoesNotModifyRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		getBody bool
+	}{
+		{name: "without GetBody"},
+		{name: "with GetBody", getBody: true},
+	}
+
+	for _, tt := range tests {
+		cassette, err := NewCassette(filepath.Join(t.TempDir(), "api.json"), CassetteOptions{Mode: CassetteRecord, Match: DefaultCassetteMatch | MatchBody})
+		if err != nil {
+			t.Fatalf("%s: new cassette failed, %v", tt.name, err)
+		}
+
+		// io.NopCloser包装后http.NewRequest不会设置GetBody
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, io.NopCloser(strings.NewReader("payload")))
+		if tt.getBody {
+			req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+		}
+		body, getBody := req.Body, req.GetBody
+
+		resp, err := cassette.Transport(nil).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: round trip failed, %v", tt.name, err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(data) != "payload" {
+			t.Fatalf("%s: server should receive body, got %q", tt.name, data)
+		}
+		if req.Body != body || (req.GetBody == nil) != (getBody == nil) || resp.Request != req {
+			t.Fatalf("%s: request should not be modified", tt.name)
+		}
+	}
+}
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCassetteReplayClosesBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	path := filepath.Join(t.TempDir(), "api.json")
+	recorder, err := NewCassette(path, CassetteOptions{Mode: CassetteRecord})
+	if err != nil {
+		t.Fatalf("new cassette failed, %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	resp, err := recorder.Transport(nil).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("record failed, %v", err)
+	}
+	resp.Body.Close()
+	srv.Close()
+
+	player, err := NewCassette(path, CassetteOptions{Mode: CassetteReplay})
+	if err != nil {
+		t.Fatalf("open cassette failed, %v", err)
+	}
+	transport := player.Transport(nil)
+
+	// 第二次请求没有可回放的记录，出错时也要关闭body
+	for i, matched := range []bool{true, false} {
+		body := &closeTracker{Reader: strings.NewReader("payload")}
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, body)
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("payload")), nil
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if (err == nil) != matched {
+			t.Fatalf("request %d: expected matched %v, err %v", i, matched, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if !body.closed {
+			t.Fatalf("request %d: body should be closed", i)
+		}
+	}
+}
@@ -1,140 +1,83 @@
 package httpkit
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
-
-	"github.com/moul/http2curl"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type HttpClient struct {
-	c                 *http.Client
-	rwTimeout         time.Duration
-	params            url.Values
-	headers           http.Header
-	cookie            *http.Cookie
-	body              io.Reader
-	rawBody           []byte //原始body备份使用，retry的时候使用
-	baseAuth          bool
-	baseAuthUsername  string
-	baseAuthPassword  string
-	gzip              bool
-	retry             int
-	retryInterval     time.Duration
-	retryHttpStatuses []int // 重试的http状态码
-
-	ctx    context.Context
-	cancel context.CancelFunc
+	engine *engine
+	requestOptions
 }
 
 func NewHttpClient(rwTimeout time.Duration, retry int,
 	retryInterval, connTimeout time.Duration, tlsCfg *tls.Config,
 	retryHttpStatuses ...int,
 ) *HttpClient {
-	tr := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   connTimeout,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-
-		TLSClientConfig:       tlsCfg,
-		DisableKeepAlives:     false,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-
-	client := &http.Client{
-		Transport: tr,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
 	return &HttpClient{
-		c:                 client,
-		params:            url.Values{},
-		headers:           http.Header{},
-		rwTimeout:         rwTimeout,
-		baseAuth:          false,
-		gzip:              false,
-		retry:             retry,
-		retryInterval:     retryInterval,
-		retryHttpStatuses: retryHttpStatuses,
+		engine:         newEngine(nil, newTransport(connTimeout, tlsCfg, 100, 0)),
+		requestOptions: newRequestOptions(rwTimeout, retry, retryInterval, retryHttpStatuses),
 	}
 }
 
+// NewHttpClientWithTransport transport为nil时与NewHttpClient相同
 func NewHttpClientWithTransport(rwTimeout time.Duration, retry int,
 	retryInterval, connTimeout time.Duration, tlsCfg *tls.Config,
 	transport http.RoundTripper,
 	retryHttpStatuses ...int,
 ) *HttpClient {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
-
-	if transport != nil {
-		client.Transport = transport
-	} else {
-		client.Transport = &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   connTimeout,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-
-			TLSClientConfig:       tlsCfg,
-			DisableKeepAlives:     false,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
-	}
-
 	return &HttpClient{
-		c:                 client,
-		params:            url.Values{},
-		headers:           http.Header{},
-		rwTimeout:         rwTimeout,
-		baseAuth:          false,
-		gzip:              false,
-		retry:             retry,
-		retryInterval:     retryInterval,
-		retryHttpStatuses: retryHttpStatuses,
+		engine:         newEngine(transport, newTransport(connTimeout, tlsCfg, 100, 0)),
+		requestOptions: newRequestOptions(rwTimeout, retry, retryInterval, retryHttpStatuses),
 	}
 }
 
+// EnableGZip 保留兼容
+//
+// Deprecated: 响应会按Content-Encoding自动解压gzip、deflate、zstd，无需再调用
 func (client *HttpClient) EnableGZip(gzip bool) *HttpClient {
-	client.gzip = gzip
+	return client
+}
+
+// SetRequestCompression 设置请求body的压缩方式(gzip、deflate、zstd)，body达到minSize字节才压缩
+func (client *HttpClient) SetRequestCompression(encoding string, minSize int) *HttpClient {
+	client.compress.encoding = encoding
+	client.compress.minSize = minSize
+	return client
+}
+
+// SetMaxDecompressedSize 设置响应解压后的最大字节数，防止解压炸弹，<=0不限制
+func (client *HttpClient) SetMaxDecompressedSize(size int64) *HttpClient {
+	client.compress.maxDecompressedSize = size
 	return client
 }
 
 func (client *HttpClient) EnableOtelHttp() *HttpClient {
-	client.c.Transport = otelhttp.NewTransport(
-		client.c.Transport,
-		// By setting the otelhttptrace client in this transport, it can be
-		// injected into the context after the span is started, which makes the
-		// httptrace spans children of the transport one.
-		// otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
-		// 	return otelhttptrace.NewClientTrace(ctx)
-		// }),
-	)
+	client.engine.enableOtelHttp()
+	return client
+}
 
+// SetProxy 设置代理，默认使用HTTP_PROXY等环境变量，使用自定义transport时不生效
+// 需在发起请求前设置
+func (client *HttpClient) SetProxy(proxy ProxyFunc) *HttpClient {
+	client.engine.setProxy(proxy)
+	return client
+}
+
+// EnableCassette 开启录制回放，回放时不访问网络
+func (client *HttpClient) EnableCassette(cassette *Cassette) *HttpClient {
+	client.engine.enableCassette(cassette)
+	return client
+}
+
+// EnableCache 开启响应缓存，按Cache-Control、Expires、ETag、Last-Modified缓存GET请求的响应
+func (client *HttpClient) EnableCache(storage CacheStorage) *HttpClient {
+	client.engine.enableCache(storage)
 	return client
 }
 
@@ -190,11 +133,31 @@ func (client *HttpClient) AddHeaders(headers http.Header) *HttpClient {
 	return client
 }
 
+// SetRedirectPolicy 设置跳转策略，默认不跟随跳转
+func (client *HttpClient) SetRedirectPolicy(policy RedirectPolicy) *HttpClient {
+	client.redirectPolicy = &policy
+	return client
+}
+
+// SetHedgePolicy 设置对冲请求策略，仅对GET、HEAD请求生效
+// 请求在delay时间内未返回时再发出一个相同的请求，最多额外发出maxHedges个，取最先返回的响应
+func (client *HttpClient) SetHedgePolicy(delay time.Duration, maxHedges int) *HttpClient {
+	client.hedgeDelay = delay
+	client.maxHedges = maxHedges
+	return client
+}
+
 func (client *HttpClient) SetCookie(cookie *http.Cookie) *HttpClient {
 	client.cookie = cookie
 	return client
 }
 
+// SetAuthenticator 设置认证方式，如NewBearerAuth、NewClientCredentialsAuth、NewHMACAuth
+func (client *HttpClient) SetAuthenticator(auth Authenticator) *HttpClient {
+	client.auth = auth
+	return client
+}
+
 func (client *HttpClient) SetBasicAuth(username, password string) *HttpClient {
 	client.baseAuth = true
 	client.baseAuthUsername = username
@@ -204,13 +167,11 @@ func (client *HttpClient) SetBasicAuth(username, password string) *HttpClient {
 
 func (client *HttpClient) SetBody(body io.Reader) *HttpClient {
 	rawBody, _ := io.ReadAll(body)
-	client.body = bytes.NewBuffer(rawBody)
 	client.rawBody = rawBody
 	return client
 }
 
 func (client *HttpClient) Get(targetUrl string) (*AdvanceResponse, error) {
-	client.body = nil
 	client.rawBody = []byte{}
 	return client.do(context.Background(), "GET", targetUrl)
 }
@@ -236,7 +197,6 @@ func (client *HttpClient) Do(method, targetUrl string) (*AdvanceResponse, error)
 }
 
 func (client *HttpClient) GetWithContext(ctx context.Context, targetUrl string) (*AdvanceResponse, error) {
-	client.body = nil
 	client.rawBody = []byte{}
 	return client.do(ctx, "GET", targetUrl)
 }
@@ -261,163 +221,59 @@ func (client *HttpClient) DoWithContext(ctx context.Context, method, targetUrl s
 	return client.do(ctx, method, targetUrl)
 }
 
-func (client *HttpClient) genHttpRequest(ctx context.Context, method, targetUrl string) (*http.Request, error) {
-	for _, code := range client.retryHttpStatuses {
-		if code <= 201 {
-			return nil, fmt.Errorf("设置的重试http状态码包含201及以下, %+v", client.retryHttpStatuses)
-		}
-
-		if code >= 500 {
-			return nil, fmt.Errorf("设置的重试http状态码包含500及以上服务端错误的状态码, %+v", client.retryHttpStatuses)
-		}
-	}
-
-	u, err := url.Parse(targetUrl)
+// Stream 发起流式请求，不读取body，适用于SSE、NDJSON等长连接响应
+// rwTimeout仅限制等待响应头的时间，返回的响应使用完毕后需调用Close
+func (client *HttpClient) Stream(ctx context.Context, method, targetUrl string) (*StreamResponse, error) {
+	u, err := client.parseUrl(targetUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	if u.RawQuery != "" {
-		return nil, fmt.Errorf("url中不能存在query参数[%s]，请使用client.SetParam等方法预设置", u.RawQuery)
-	}
-
-	u.RawQuery = client.params.Encode()
-	client.body = bytes.NewBuffer(client.rawBody)
+	return client.engine.stream(ctx, method, *u, &client.requestOptions)
+}
 
-	req, err := http.NewRequestWithContext(ctx, method, u.String(), client.body)
+// EventSource 创建SSE客户端，使用client当前的请求头、query参数及重试设置
+func (client *HttpClient) EventSource(targetUrl string) (*EventSource, error) {
+	u, err := client.parseUrl(targetUrl)
 	if err != nil {
 		return nil, err
 	}
 
-	for key, values := range client.headers {
-		if strings.ToLower(key) == "host" {
-			if len(values) > 0 {
-				req.Host = values[0]
-			}
-		} else {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
-		}
-	}
+	return newEventSource(client.engine, *u, &client.requestOptions), nil
+}
 
-	if client.cookie != nil {
-		req.Header.Add("Cookie", client.cookie.String())
+// HedgeStats 返回该client对冲请求的统计信息
+func (client *HttpClient) HedgeStats() HedgeStats {
+	return client.engine.stats()
+}
+
+func (client *HttpClient) parseUrl(targetUrl string) (*url.URL, error) {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return nil, err
 	}
 
-	if client.baseAuth {
-		req.SetBasicAuth(client.baseAuthUsername, client.baseAuthPassword)
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("url中不能存在query参数[%s]，请使用client.SetParam等方法预设置", u.RawQuery)
 	}
 
-	return req, nil
+	return u, nil
 }
 
 func (client *HttpClient) ToCurlCommand(ctx context.Context, method, targetUrl string) (string, error) {
-	req, err := client.genHttpRequest(ctx, method, targetUrl)
-	if err != nil {
-		return "", err
-	}
-
-	cmd, err := http2curl.GetCurlCommand(req)
+	u, err := client.parseUrl(targetUrl)
 	if err != nil {
 		return "", err
 	}
 
-	return cmd.String(), nil
+	return client.engine.curlCommand(ctx, method, *u, &client.requestOptions)
 }
 
 func (client *HttpClient) do(ctx context.Context, method, targetUrl string) (*AdvanceResponse, error) {
-	if client.retry <= 0 {
-		client.retry = 1
-	} else {
-		client.retry++
-	}
-
-	adresp := &AdvanceResponse{}
-
-	startTime := time.Now()
-	for i := 0; i < client.retry; i++ {
-		err := client.doOnce(ctx, method, targetUrl, adresp)
-		if err != nil {
-			// 达到retry的次数
-			if i == client.retry-1 {
-				return nil, err
-			}
-			time.Sleep(client.retryInterval)
-			continue
-		}
-
-		if client.retryCheck(adresp.StatusCode) {
-			if i == client.retry-1 {
-				break
-			}
-
-			time.Sleep(client.retryInterval)
-			continue
-		}
-
-		break
-	}
-
-	adresp.Time = int64(time.Now().Sub(startTime))
-
-	return adresp, nil
-}
-
-func (client *HttpClient) retryCheck(responseStatusCode int) bool {
-	for _, code := range client.retryHttpStatuses {
-		if code == responseStatusCode {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (client *HttpClient) doOnce(ctx context.Context, method, targetUrl string, adresp *AdvanceResponse) error {
-	req, err := client.genHttpRequest(ctx, method, targetUrl)
-	if err != nil {
-		return err
-	}
-
-	if client.rwTimeout > 0 {
-		ctx, cancel := context.WithTimeout(req.Context(), client.rwTimeout)
-		defer cancel()
-
-		req = req.WithContext(ctx)
-	}
-
-	resp, err := client.c.Do(req)
+	u, err := client.parseUrl(targetUrl)
 	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	adresp.Header = resp.Header
-	adresp.StatusCode = resp.StatusCode
-	adresp.Status = resp.Status
-
-	if client.gzip && resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return err
-		}
-
-		body, err := io.ReadAll(reader)
-		if err != nil {
-			return err
-		}
-
-		adresp.Body = body
-		return nil
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	adresp.Body = body
-	return nil
+	return client.engine.do(ctx, method, *u, &client.requestOptions)
 }
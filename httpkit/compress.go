@@ -0,0 +1,219 @@
+package httpkit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
+
+	// DefaultAcceptEncoding 未设置Accept-Encoding请求头时默认发送的值
+	DefaultAcceptEncoding = "gzip, deflate"
+
+	// DefaultMaxDecompressedSize 响应解压后的默认最大字节数
+	DefaultMaxDecompressedSize = 1 << 28 // 256 MB
+)
+
+var ErrDecompressedTooLarge = errors.New("httpkit: 解压后的响应body超过限制")
+
+// compressOption 请求body压缩及响应解压的设置
+type compressOption struct {
+	encoding            string // 请求body的压缩方式，为空不压缩
+	minSize             int    // 请求body达到该字节数才压缩
+	maxDecompressedSize int64  // 响应解压后的最大字节数，<=0不限制
+}
+
+func defaultCompressOption() compressOption {
+	return compressOption{
+		maxDecompressedSize: DefaultMaxDecompressedSize,
+	}
+}
+
+func (opt compressOption) validate() error {
+	switch opt.encoding {
+	case "", EncodingGzip, EncodingDeflate, EncodingZstd:
+		return nil
+	}
+
+	return fmt.Errorf("不支持的请求body压缩方式[%s]", opt.encoding)
+}
+
+// encodeBody 按设置压缩请求body，返回压缩后的body及Content-Encoding，不需要压缩时原样返回
+func (opt compressOption) encodeBody(body []byte) ([]byte, string, error) {
+	if opt.encoding == "" || len(body) == 0 || len(body) < opt.minSize {
+		return body, "", nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	var w io.WriteCloser
+	switch opt.encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(buf)
+	case EncodingDeflate:
+		w = zlib.NewWriter(buf)
+	case EncodingZstd:
+		zw, err := zstd.NewWriter(buf)
+		if err != nil {
+			return nil, "", err
+		}
+		w = zw
+	default:
+		return nil, "", opt.validate()
+	}
+
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return nil, "", err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), opt.encoding, nil
+}
+
+// prepareRequest 设置请求的压缩body和Accept-Encoding
+func (opt compressOption) prepareRequest(req *http.Request, body []byte) error {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", DefaultAcceptEncoding)
+	}
+
+	// 调用方已自行压缩body
+	if req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	encoded, encoding, err := opt.encodeBody(body)
+	if err != nil {
+		return err
+	}
+
+	if encoding == "" {
+		return nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(encoded))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(encoded)), nil
+	}
+	req.ContentLength = int64(len(encoded))
+	req.Header.Set("Content-Encoding", encoding)
+
+	return nil
+}
+
+// readBody 读取响应body，按Content-Encoding自动解压
+func (opt compressOption) readBody(resp *http.Response) ([]byte, error) {
+	body, err := opt.decodeBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if !resp.Uncompressed || opt.maxDecompressedSize <= 0 {
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, opt.maxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > opt.maxDecompressedSize {
+		return nil, ErrDecompressedTooLarge
+	}
+
+	return data, nil
+}
+
+// decodeBody 按Content-Encoding返回解压后的body，关闭时同时关闭resp.Body
+// 不限制解压后的大小，供流式读取使用
+func (opt compressOption) decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	var reader io.ReadCloser
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err == io.EOF {
+			return resp.Body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		reader = gr
+	case EncodingDeflate:
+		dr, err := newDeflateReader(resp.Body)
+		if err == io.EOF {
+			return resp.Body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		reader = dr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		reader = zr.IOReadCloser()
+	default:
+		return resp.Body, nil
+	}
+
+	// 与标准库一致，解压后删除相关响应头
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return &decodedBody{Reader: reader, decoder: reader, body: resp.Body}, nil
+}
+
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	err := b.decoder.Close()
+	if berr := b.body.Close(); err == nil {
+		err = berr
+	}
+	return err
+}
+
+// newDeflateReader HTTP的deflate应为zlib格式，但不少服务端直接返回raw deflate，需兼容两种格式
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		if len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	// zlib头: CMF低4位为8(deflate)，且CMF*256+FLG能被31整除
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,123 @@
+package httpkit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestHttpClientDecompress(t *testing.T) {
+	payload := strings.Repeat("httpkit", 100)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+		var cw io.WriteCloser
+		switch r.URL.Path {
+		case "/gzip":
+			cw = gzip.NewWriter(buf)
+		case "/deflate":
+			cw = zlib.NewWriter(buf)
+		case "/raw-deflate":
+			cw, _ = flate.NewWriter(buf, flate.DefaultCompression)
+		}
+
+		if r.Header.Get("Accept-Encoding") != DefaultAcceptEncoding {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		cw.Write([]byte(payload))
+		cw.Close()
+
+		encoding := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"), "raw-")
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/gzip", "/deflate", "/raw-deflate"} {
+		client := NewHttpClient(time.Second, 0, 0, time.Second, nil)
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s: get failed, %v", path, err)
+		}
+
+		if resp.StatusCode != http.StatusOK || string(resp.Body) != payload {
+			t.Fatalf("%s: unexpected response %d %q", path, resp.StatusCode, resp.Body)
+		}
+
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%s: Content-Encoding should be removed after decompress", path)
+		}
+
+		resp, err = client.SetMaxDecompressedSize(10).Get(srv.URL + path)
+		if err != ErrDecompressedTooLarge {
+			t.Fatalf("%s: expected ErrDecompressedTooLarge, got %v", path, err)
+		}
+	}
+}
+
+func TestAdvanceHttpClientRequestCompression(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		switch r.Header.Get("Content-Encoding") {
+		case EncodingGzip:
+			gr, _ := gzip.NewReader(r.Body)
+			body, _ = io.ReadAll(gr)
+		case EncodingDeflate:
+			zr, _ := zlib.NewReader(r.Body)
+			body, _ = io.ReadAll(zr)
+		case EncodingZstd:
+			zr, _ := zstd.NewReader(r.Body)
+			body, _ = io.ReadAll(zr)
+			zr.Close()
+		default:
+			body, _ = io.ReadAll(r.Body)
+		}
+
+		w.Write([]byte(r.Header.Get("Content-Encoding") + ":" + string(body)))
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+
+	tests := []struct {
+		encoding string
+		body     string
+		want     string
+	}{
+		{EncodingGzip, strings.Repeat("a", 64), "gzip:" + strings.Repeat("a", 64)},
+		{EncodingDeflate, strings.Repeat("b", 64), "deflate:" + strings.Repeat("b", 64)},
+		{EncodingZstd, strings.Repeat("c", 64), "zstd:" + strings.Repeat("c", 64)},
+		{EncodingGzip, "small", ":small"},
+	}
+
+	for _, tt := range tests {
+		setting := NewAdvanceSettings(time.Second, 1, 0).
+			SetRequestCompression(tt.encoding, 32).
+			SetBody(strings.NewReader(tt.body))
+
+		resp, err := client.Post("/compress", setting)
+		if err != nil {
+			t.Fatalf("%s: post failed, %v", tt.encoding, err)
+		}
+
+		if string(resp.Body) != tt.want {
+			t.Fatalf("%s: expected %q, got %q", tt.encoding, tt.want, resp.Body)
+		}
+	}
+
+	setting := NewAdvanceSettings(time.Second, 0, 0).SetRequestCompression("br", 0)
+	if _, err := client.Post("/compress", setting); err == nil {
+		t.Fatalf("unsupported encoding should fail")
+	}
+}
@@ -0,0 +1,370 @@
+package httpkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/moul/http2curl"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// requestOptions 单次请求的设置，HttpClient与AdvanceSettings共用
+type requestOptions struct {
+	rwTimeout         time.Duration
+	params            url.Values
+	headers           http.Header
+	cookie            *http.Cookie
+	rawBody           []byte //原始body备份使用，retry的时候使用
+	baseAuth          bool
+	baseAuthUsername  string
+	baseAuthPassword  string
+	compress          compressOption
+	retry             int
+	retryInterval     time.Duration
+	retryHttpStatuses []int // 重试的http状态码
+	redirectPolicy    *RedirectPolicy
+	hedgeDelay        time.Duration
+	maxHedges         int
+	auth              Authenticator
+}
+
+func newRequestOptions(rwTimeout time.Duration, retry int, retryInterval time.Duration, retryHttpStatuses []int) requestOptions {
+	return requestOptions{
+		rwTimeout:         rwTimeout,
+		params:            url.Values{},
+		headers:           http.Header{},
+		compress:          defaultCompressOption(),
+		retry:             retry,
+		retryInterval:     retryInterval,
+		retryHttpStatuses: retryHttpStatuses,
+	}
+}
+
+func (opts *requestOptions) validate() error {
+	for _, code := range opts.retryHttpStatuses {
+		if code <= 201 {
+			return fmt.Errorf("设置的重试http状态码包含201及以下, %+v", opts.retryHttpStatuses)
+		}
+
+		if code >= 500 {
+			return fmt.Errorf("设置的重试http状态码包含500及以上服务端错误的状态码, %+v", opts.retryHttpStatuses)
+		}
+	}
+
+	return opts.compress.validate()
+}
+
+// clone 复制设置，params、headers不与原设置共享
+func (opts *requestOptions) clone() requestOptions {
+	c := *opts
+	c.headers = opts.headers.Clone()
+	c.params = make(url.Values, len(opts.params))
+	for key, values := range opts.params {
+		c.params[key] = append([]string(nil), values...)
+	}
+	return c
+}
+
+func (opts *requestOptions) retryCheck(responseStatusCode int) bool {
+	for _, code := range opts.retryHttpStatuses {
+		if code == responseStatusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// engine HttpClient与AdvanceHttpClient共用的请求引擎
+type engine struct {
+	client *http.Client
+	// 由本包创建的transport，使用自定义transport时为nil
+	transport *http.Transport
+
+	hedgeStats hedgeCounter
+}
+
+func newTransport(connTimeout time.Duration, tlsCfg *tls.Config, maxIdleConns, maxIdleConnsPerHost int) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   connTimeout,
+		KeepAlive: 30 * time.Second,
+		DualStack: true,
+	}
+
+	tr := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: dialer.DialContext,
+
+		TLSClientConfig:       tlsCfg,
+		DisableKeepAlives:     false,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if tlsCfg != nil {
+		tr.DialTLSContext = dialTLSContext(dialer, tlsCfg, tr.TLSHandshakeTimeout)
+	}
+
+	return tr
+}
+
+// newEngine transport为nil时使用tr，tr也为nil时创建默认的transport
+func newEngine(transport http.RoundTripper, tr *http.Transport) *engine {
+	e := &engine{
+		client: &http.Client{
+			CheckRedirect: checkRedirect,
+		},
+	}
+
+	if transport != nil {
+		e.client.Transport = transport
+	} else {
+		e.transport = tr
+		e.client.Transport = tr
+	}
+
+	return e
+}
+
+func (e *engine) setProxy(proxy ProxyFunc) {
+	if e.transport != nil {
+		e.transport.Proxy = proxy
+	}
+}
+
+func (e *engine) enableOtelHttp() {
+	e.client.Transport = otelhttp.NewTransport(
+		e.client.Transport,
+		// By setting the otelhttptrace client in this transport, it can be
+		// injected into the context after the span is started, which makes the
+		// httptrace spans children of the transport one.
+		// otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
+		// 	return otelhttptrace.NewClientTrace(ctx)
+		// }),
+	)
+}
+
+func (e *engine) enableCassette(cassette *Cassette) {
+	e.client.Transport = cassette.Transport(e.client.Transport)
+}
+
+func (e *engine) enableCache(storage CacheStorage) {
+	e.client.Transport = newCacheTransport(e.client.Transport, storage)
+}
+
+// newRequest 根据设置构造请求，u中不含query，query使用opts.params
+func (e *engine) newRequest(ctx context.Context, method string, u url.URL, opts *requestOptions) (*http.Request, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	u.RawQuery = opts.params.Encode()
+
+	req, err := http.NewRequestWithContext(withRedirectPolicy(ctx, opts.redirectPolicy), method, u.String(), bytes.NewReader(opts.rawBody))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range opts.headers {
+		if strings.ToLower(key) == "host" {
+			if len(values) > 0 {
+				req.Host = values[0]
+			}
+		} else {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+
+	if opts.cookie != nil {
+		req.Header.Add("Cookie", opts.cookie.String())
+	}
+
+	if opts.baseAuth {
+		req.SetBasicAuth(opts.baseAuthUsername, opts.baseAuthPassword)
+	}
+
+	if err := opts.compress.prepareRequest(req, opts.rawBody); err != nil {
+		return nil, err
+	}
+
+	// 签名类的认证需要使用压缩后的body，放在最后
+	if opts.auth != nil {
+		if err := opts.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func (e *engine) curlCommand(ctx context.Context, method string, u url.URL, opts *requestOptions) (string, error) {
+	req, err := e.newRequest(ctx, method, u, opts)
+	if err != nil {
+		return "", err
+	}
+
+	cmd, err := http2curl.GetCurlCommand(req)
+	if err != nil {
+		return "", err
+	}
+
+	return cmd.String(), nil
+}
+
+func (e *engine) do(ctx context.Context, method string, u url.URL, opts *requestOptions) (*AdvanceResponse, error) {
+	attempts := 1
+	if opts.retry > 0 {
+		attempts += opts.retry
+	}
+
+	adresp := &AdvanceResponse{}
+
+	startTime := time.Now()
+	for i := 0; i < attempts; i++ {
+		// solve Golang http post error : http: ContentLength=355 with Body length 0 bug
+		err := e.doOnce(ctx, method, u, opts, adresp)
+		if err != nil {
+			// 达到retry的次数或请求被取消
+			if i == attempts-1 || ctx.Err() != nil {
+				return nil, err
+			}
+			if err := sleepContext(ctx, opts.retryInterval); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if opts.retryCheck(adresp.StatusCode) {
+			if i == attempts-1 {
+				break
+			}
+
+			if err := sleepContext(ctx, opts.retryInterval); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		break
+	}
+
+	adresp.Time = int64(time.Since(startTime))
+
+	return adresp, nil
+}
+
+func (e *engine) doOnce(ctx context.Context, method string, u url.URL, opts *requestOptions, adresp *AdvanceResponse) error {
+	if opts.hedgeable(method) {
+		return e.doHedged(ctx, method, u, opts, adresp)
+	}
+
+	req, err := e.newRequest(ctx, method, u, opts)
+	if err != nil {
+		return err
+	}
+
+	return e.roundTrip(req, opts, adresp)
+}
+
+func (e *engine) roundTrip(req *http.Request, opts *requestOptions, adresp *AdvanceResponse) error {
+	err := e.roundTripOnce(req, opts, adresp)
+	if err != nil || adresp.StatusCode != http.StatusUnauthorized {
+		return err
+	}
+
+	retryReq, err := opts.reauthenticate(req.Context(), req)
+	if err != nil || retryReq == nil {
+		return err
+	}
+
+	return e.roundTripOnce(retryReq, opts, adresp)
+}
+
+// reauthenticate 请求返回401且认证支持刷新时，刷新凭证并返回重新认证的请求，否则返回nil
+func (opts *requestOptions) reauthenticate(ctx context.Context, req *http.Request) (*http.Request, error) {
+	refresher, ok := opts.auth.(Refresher)
+	if !ok {
+		return nil, nil
+	}
+
+	if err := refresher.Refresh(ctx, req); err != nil {
+		return nil, err
+	}
+
+	retryReq := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	resetRedirectHops(retryReq)
+
+	if err := opts.auth.Authenticate(retryReq); err != nil {
+		return nil, err
+	}
+
+	return retryReq, nil
+}
+
+func (e *engine) roundTripOnce(req *http.Request, opts *requestOptions, adresp *AdvanceResponse) error {
+	if opts.rwTimeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), opts.rwTimeout)
+		defer cancel()
+
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	adresp.CacheStatus = CacheStatus(resp.Header.Get(cacheStatusHeader))
+	resp.Header.Del(cacheStatusHeader)
+
+	adresp.Header = resp.Header
+	adresp.StatusCode = resp.StatusCode
+	adresp.Status = resp.Status
+	adresp.Redirects = redirectHops(req)
+
+	body, err := opts.compress.readBody(resp)
+	if err != nil {
+		return err
+	}
+
+	adresp.Body = body
+	return nil
+}
+
+// sleepContext 等待d时间，ctx被取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdvanceHttpClientContextCancel(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	setting := NewAdvanceSettings(5*time.Second, 3, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.GetWithContext(ctx, "/slow", setting); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("cancelled request should not retry, took %v", time.Since(start))
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 call, got %d", n)
+	}
+}
+
+func TestEngineSharedBehaviour(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.URL.RawQuery))
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil).
+		SetHeader("Host", "example.com").
+		SetParam("a", "1")
+	resp, err := client.Get(srv.URL + "/echo")
+	if err != nil {
+		t.Fatalf("get failed, %v", err)
+	}
+
+	advance := NewAdvanceHttpClient("http", host, time.Second, nil)
+	setting := NewAdvanceSettings(time.Second, 0, 0).
+		SetHeader("Host", "example.com").
+		SetParam("a", "1")
+	adresp, err := advance.Get("/echo", setting)
+	if err != nil {
+		t.Fatalf("advance get failed, %v", err)
+	}
+
+	if string(resp.Body) != "example.com a=1" || string(adresp.Body) != string(resp.Body) {
+		t.Fatalf("clients diverged, %q %q", resp.Body, adresp.Body)
+	}
+
+	if _, err := advance.Get("/echo?a=1", setting); err == nil {
+		t.Fatalf("query in uri should be rejected")
+	}
+
+	// 重试次数不应在多次请求间累加
+	setting.SetRetryHttpStatuses([]int{http.StatusTooManyRequests})
+	for i := 0; i < 2; i++ {
+		if _, err := advance.Get("/echo", setting); err != nil {
+			t.Fatalf("get failed, %v", err)
+		}
+	}
+	if setting.retry != 0 {
+		t.Fatalf("retry should not be mutated, got %d", setting.retry)
+	}
+}
@@ -0,0 +1,121 @@
+package httpkit
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// HedgeStats 对冲请求的统计信息
+type HedgeStats struct {
+	Requests  int64 // 启用对冲策略的请求数
+	Hedges    int64 // 额外发出的对冲请求数
+	HedgeWins int64 // 对冲请求先于首个请求返回的次数
+}
+
+type hedgeCounter struct {
+	requests atomic.Int64
+	hedges   atomic.Int64
+	wins     atomic.Int64
+}
+
+type hedgeResult struct {
+	index int
+	resp  *AdvanceResponse
+	err   error
+}
+
+// hedgeable 对冲策略仅对GET、HEAD等幂等请求生效
+func (opts *requestOptions) hedgeable(method string) bool {
+	if opts.hedgeDelay <= 0 || opts.maxHedges <= 0 {
+		return false
+	}
+
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func (e *engine) stats() HedgeStats {
+	return HedgeStats{
+		Requests:  e.hedgeStats.requests.Load(),
+		Hedges:    e.hedgeStats.hedges.Load(),
+		HedgeWins: e.hedgeStats.wins.Load(),
+	}
+}
+
+// doHedged 首个请求在hedgeDelay时间内未返回时再发出一个相同的请求，最多额外发出maxHedges个，
+// 取最先成功返回的响应，其余请求会被取消
+func (e *engine) doHedged(ctx context.Context, method string, u url.URL, opts *requestOptions, adresp *AdvanceResponse) error {
+	// 返回时取消仍未完成的请求
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.hedgeStats.requests.Add(1)
+
+	results := make(chan hedgeResult, opts.maxHedges+1)
+	launch := func(index int) error {
+		req, err := e.newRequest(ctx, method, u, opts)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			resp := &AdvanceResponse{}
+			err := e.roundTrip(req, opts, resp)
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+
+		return nil
+	}
+
+	if err := launch(0); err != nil {
+		return err
+	}
+
+	launched, pending := 1, 1
+	timer := time.NewTimer(opts.hedgeDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if launched > opts.maxHedges {
+				continue
+			}
+
+			if err := launch(launched); err != nil {
+				return err
+			}
+			e.hedgeStats.hedges.Add(1)
+			launched++
+			pending++
+			timer.Reset(opts.hedgeDelay)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if r.index > 0 {
+					e.hedgeStats.wins.Add(1)
+				}
+				*adresp = *r.resp
+				return nil
+			}
+
+			lastErr = r.err
+			// 请求全部失败且还有对冲余量时，立即发出下一个请求
+			if pending == 0 && launched <= opts.maxHedges {
+				if err := launch(launched); err != nil {
+					return err
+				}
+				e.hedgeStats.hedges.Add(1)
+				launched++
+				pending++
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return lastErr
+}
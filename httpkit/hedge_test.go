@@ -0,0 +1,67 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdvanceHttpClientHedge(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 首个请求很慢，对冲请求立即返回
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte("hedge"))
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	setting := NewAdvanceSettings(5*time.Second, 0, 0).SetHedgePolicy(50*time.Millisecond, 1)
+
+	resp, err := client.Get("/hedge", setting)
+	if err != nil {
+		t.Fatalf("hedge get failed, %v", err)
+	}
+
+	if string(resp.Body) != "hedge" {
+		t.Fatalf("expected hedge response, got %q", resp.Body)
+	}
+
+	if time.Duration(resp.Time) > time.Second {
+		t.Fatalf("hedged request took too long, %v", time.Duration(resp.Time))
+	}
+
+	stats := client.HedgeStats()
+	if stats.Requests != 1 || stats.Hedges != 1 || stats.HedgeWins != 1 {
+		t.Fatalf("unexpected hedge stats, %+v", stats)
+	}
+}
+
+func TestAdvanceHttpClientHedgeSkipPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	setting := NewAdvanceSettings(5*time.Second, 0, 0).SetHedgePolicy(10*time.Millisecond, 2)
+
+	if _, err := client.Post("/hedge", setting); err != nil {
+		t.Fatalf("post failed, %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("post should not be hedged, got %d calls", calls.Load())
+	}
+}
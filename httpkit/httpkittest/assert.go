@@ -0,0 +1,46 @@
+package httpkittest
+
+import (
+	"testing"
+)
+
+// AssertCalled 断言method、path至少被请求过一次
+func (t *Transport) AssertCalled(tb testing.TB, method, path string) {
+	tb.Helper()
+
+	if len(t.CallsTo(method, path)) == 0 {
+		tb.Errorf("httpkittest: expected %s %s to be called", method, path)
+	}
+}
+
+// AssertNotCalled 断言method、path没有被请求过
+func (t *Transport) AssertNotCalled(tb testing.TB, method, path string) {
+	tb.Helper()
+
+	if n := len(t.CallsTo(method, path)); n != 0 {
+		tb.Errorf("httpkittest: expected %s %s not to be called, got %d calls", method, path, n)
+	}
+}
+
+// AssertCallCount 断言method、path被请求的次数
+func (t *Transport) AssertCallCount(tb testing.TB, method, path string, count int) {
+	tb.Helper()
+
+	if n := len(t.CallsTo(method, path)); n != count {
+		tb.Errorf("httpkittest: expected %s %s to be called %d times, got %d", method, path, count, n)
+	}
+}
+
+// AssertAllConsumed 断言每个路由排队的响应都已被使用
+func (t *Transport) AssertAllConsumed(tb testing.TB) {
+	tb.Helper()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, route := range t.routes {
+		if route.served < len(route.responses) {
+			tb.Errorf("httpkittest: %s %s has %d unused responses", route.method, route.path, len(route.responses)-route.served)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package httpkittest 提供可编程的mock RoundTripper，
+// 通过httpkit.NewHttpClientWithTransport、httpkit.NewAdvanceHttpClientWithTransport注入，无需启动httptest server
+package httpkittest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrConnRefused 模拟连接被拒绝
+	ErrConnRefused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	// ErrConnReset 模拟连接被重置
+	ErrConnReset = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+)
+
+// Response mock的一个响应
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Err        error         // 不为nil时返回该错误，模拟连接错误
+	Delay      time.Duration // 返回前的延迟，期间请求被取消时返回ctx.Err()
+}
+
+// Call 记录收到的一次请求
+type Call struct {
+	Method string
+	URL    string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Route 按method、path、query匹配请求，响应按顺序返回，用完后重复最后一个
+type Route struct {
+	method    string
+	path      string
+	query     url.Values
+	latency   time.Duration
+	responses []Response
+	served    int
+}
+
+// Transport 可编程的mock http.RoundTripper，并发安全
+type Transport struct {
+	lock   sync.Mutex
+	routes []*Route
+	calls  []Call
+}
+
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On 注册路由，method为空匹配任意方法，path以*结尾时按前缀匹配，先注册的路由优先
+func (t *Transport) On(method, path string) *Route {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	route := &Route{
+		method: strings.ToUpper(method),
+		path:   path,
+		query:  url.Values{},
+	}
+	t.routes = append(t.routes, route)
+
+	return route
+}
+
+// WithQuery 要求请求包含该query参数
+func (route *Route) WithQuery(key, value string) *Route {
+	route.query.Add(key, value)
+	return route
+}
+
+// Latency 该路由所有响应额外的延迟
+func (route *Route) Latency(d time.Duration) *Route {
+	route.latency = d
+	return route
+}
+
+// Respond 按顺序追加响应
+func (route *Route) Respond(resps ...Response) *Route {
+	route.responses = append(route.responses, resps...)
+	return route
+}
+
+func (route *Route) Reply(statusCode int, body string) *Route {
+	return route.Respond(Response{StatusCode: statusCode, Body: []byte(body)})
+}
+
+func (route *Route) ReplyJSON(statusCode int, v interface{}) *Route {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("httpkittest: json marshal failed, %v", err))
+	}
+
+	return route.Respond(Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       body,
+	})
+}
+
+// Fail 追加一个连接错误
+func (route *Route) Fail(err error) *Route {
+	return route.Respond(Response{Err: err})
+}
+
+func (route *Route) match(req *http.Request) bool {
+	if route.method != "" && route.method != req.Method {
+		return false
+	}
+
+	if prefix, ok := strings.CutSuffix(route.path, "*"); ok {
+		if !strings.HasPrefix(req.URL.Path, prefix) {
+			return false
+		}
+	} else if route.path != req.URL.Path {
+		return false
+	}
+
+	query := req.URL.Query()
+	for key, values := range route.query {
+		for _, value := range values {
+			if !contains(query[key], value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call := Call{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+	}
+
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		call.Body = body
+	}
+
+	t.lock.Lock()
+	t.calls = append(t.calls, call)
+
+	var route *Route
+	for _, r := range t.routes {
+		if r.match(req) {
+			route = r
+			break
+		}
+	}
+
+	if route == nil || len(route.responses) == 0 {
+		t.lock.Unlock()
+		return nil, fmt.Errorf("httpkittest: no route for %s %s", req.Method, req.URL.String())
+	}
+
+	resp := route.responses[min(route.served, len(route.responses)-1)]
+	route.served++
+	latency := route.latency
+	t.lock.Unlock()
+
+	if delay := latency + resp.Delay; delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Calls 返回收到的所有请求
+func (t *Transport) Calls() []Call {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]Call{}, t.calls...)
+}
+
+// CallsTo 返回匹配method、path的请求，method为空匹配任意方法
+func (t *Transport) CallsTo(method, path string) []Call {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	calls := []Call{}
+	for _, call := range t.calls {
+		if (method == "" || strings.EqualFold(method, call.Method)) && call.Path == path {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Reset 清空记录的请求并重置各路由的响应顺序
+func (t *Transport) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.calls = nil
+	for _, route := range t.routes {
+		route.served = 0
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
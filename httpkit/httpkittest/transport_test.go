@@ -0,0 +1,63 @@
+package httpkittest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xkeyideal/gokit/httpkit"
+)
+
+func TestTransportRetry(t *testing.T) {
+	mock := NewTransport()
+	mock.On(http.MethodGet, "/users").WithQuery("id", "1").
+		Fail(ErrConnRefused).
+		Reply(http.StatusTooManyRequests, "slow down").
+		ReplyJSON(http.StatusOK, map[string]string{"name": "abv"})
+	mock.On(http.MethodGet, "/users").Reply(http.StatusNotFound, "")
+
+	client := httpkit.NewHttpClientWithTransport(time.Second, 2, time.Millisecond, time.Second, nil, mock, http.StatusTooManyRequests)
+	resp, err := client.SetParam("id", "1").Get("http://mock/users")
+	if err != nil {
+		t.Fatalf("get failed, %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != `{"name":"abv"}` {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Body)
+	}
+
+	mock.AssertCallCount(t, http.MethodGet, "/users", 3)
+	mock.AssertNotCalled(t, http.MethodPost, "/users")
+
+	client = httpkit.NewHttpClientWithTransport(time.Second, 0, 0, time.Second, nil, mock)
+	resp, err = client.SetParam("id", "2").Get("http://mock/users")
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("query mismatch should use next route, %v %+v", err, resp)
+	}
+
+	mock.AssertAllConsumed(t)
+}
+
+func TestTransportLatency(t *testing.T) {
+	mock := NewTransport()
+	mock.On("", "/slow/*").Latency(time.Second).Reply(http.StatusOK, "late")
+
+	client := httpkit.NewAdvanceHttpClientWithTransport("http", "mock", time.Second, nil, mock)
+	setting := httpkit.NewAdvanceSettings(20*time.Millisecond, 0, 0).SetBody(strings.NewReader("payload"))
+
+	if _, err := client.Post("/slow/path", setting); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	calls := mock.CallsTo(http.MethodPost, "/slow/path")
+	if len(calls) != 1 || string(calls[0].Body) != "payload" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+
+	if _, err := client.Get("/missing", httpkit.NewAdvanceSettings(time.Second, 0, 0)); err == nil {
+		t.Fatalf("request without route should fail")
+	}
+}
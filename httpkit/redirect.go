@@ -0,0 +1,86 @@
+package httpkit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// RedirectPolicy 跳转策略，零值表示不跟随任何跳转
+// 307、308跳转会使用原始body重放请求
+type RedirectPolicy struct {
+	MaxHops      int      // 最多跟随的跳转次数，<=0不跟随
+	SameHostOnly bool     // 只跟随同host的跳转
+	StripAuth    bool     // 跨host跳转时删除Authorization、Proxy-Authorization、Cookie请求头
+	AuthHeaders  []string // StripAuth时额外删除的认证请求头，如Auth-Token
+}
+
+// RedirectHop 跟随的一次跳转
+type RedirectHop struct {
+	StatusCode int    // 触发跳转的响应状态码
+	URL        string // 跳转的目标地址
+}
+
+var defaultAuthHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type redirectKey struct{}
+
+type redirectState struct {
+	policy RedirectPolicy
+	hops   []RedirectHop
+}
+
+func withRedirectPolicy(ctx context.Context, policy *RedirectPolicy) context.Context {
+	if policy == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, redirectKey{}, &redirectState{policy: *policy})
+}
+
+// redirectHops 返回请求实际跟随的跳转链
+func redirectHops(req *http.Request) []RedirectHop {
+	state, ok := req.Context().Value(redirectKey{}).(*redirectState)
+	if !ok {
+		return nil
+	}
+
+	return state.hops
+}
+
+// resetRedirectHops 重发请求前清空已记录的跳转链
+func resetRedirectHops(req *http.Request) {
+	if state, ok := req.Context().Value(redirectKey{}).(*redirectState); ok {
+		state.hops = nil
+	}
+}
+
+// checkRedirect 作为http.Client的CheckRedirect，未设置策略时与之前一样不跟随跳转
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	state, ok := req.Context().Value(redirectKey{}).(*redirectState)
+	if !ok || len(via) > state.policy.MaxHops {
+		return http.ErrUseLastResponse
+	}
+
+	crossHost := !strings.EqualFold(req.URL.Host, via[0].URL.Host)
+	if crossHost && state.policy.SameHostOnly {
+		return http.ErrUseLastResponse
+	}
+
+	if crossHost && state.policy.StripAuth {
+		for _, key := range defaultAuthHeaders {
+			req.Header.Del(key)
+		}
+		for _, key := range state.policy.AuthHeaders {
+			req.Header.Del(key)
+		}
+	}
+
+	hop := RedirectHop{URL: req.URL.String()}
+	if req.Response != nil {
+		hop.StatusCode = req.Response.StatusCode
+	}
+	state.hops = append(state.hops, hop)
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package httpkit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdvanceHttpClientRedirect(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other:" + r.Header.Get("Authorization") + r.Header.Get("Auth-Token")))
+	}))
+	defer other.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			w.Write([]byte("c"))
+		case "/cross":
+			http.Redirect(w, r, other.URL+"/x", http.StatusFound)
+		case "/store":
+			http.Redirect(w, r, "/store/real", http.StatusTemporaryRedirect)
+		case "/store/real":
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.Method + ":" + string(body)))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		policy *RedirectPolicy
+		status int
+		body   string
+		hops   int
+	}{
+		{"no policy", http.MethodGet, "/a", nil, http.StatusFound, "", 0},
+		{"zero policy", http.MethodGet, "/a", &RedirectPolicy{}, http.StatusFound, "", 0},
+		{"one hop", http.MethodGet, "/a", &RedirectPolicy{MaxHops: 1}, http.StatusFound, "", 1},
+		{"follow", http.MethodGet, "/a", &RedirectPolicy{MaxHops: 5}, http.StatusOK, "c", 2},
+		{"same host only", http.MethodGet, "/cross", &RedirectPolicy{MaxHops: 5, SameHostOnly: true}, http.StatusFound, "", 0},
+		{"cross host", http.MethodGet, "/cross", &RedirectPolicy{MaxHops: 5}, http.StatusOK, "other:Basic dTpwToken", 1},
+		{"strip auth", http.MethodGet, "/cross", &RedirectPolicy{MaxHops: 5, StripAuth: true, AuthHeaders: []string{"Auth-Token"}}, http.StatusOK, "other:", 1},
+		{"307 replay body", http.MethodPost, "/store", &RedirectPolicy{MaxHops: 1}, http.StatusOK, "POST:payload", 1},
+	}
+
+	for _, tt := range tests {
+		setting := NewAdvanceSettings(time.Second, 0, 0).
+			SetBasicAuth("u", "p").
+			SetHeader("Auth-Token", "Token").
+			SetBody(strings.NewReader("payload"))
+		if tt.policy != nil {
+			setting.SetRedirectPolicy(*tt.policy)
+		}
+
+		resp, err := client.Do(tt.method, tt.path, setting)
+		if err != nil {
+			t.Fatalf("%s: request failed, %v", tt.name, err)
+		}
+
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.status, resp.StatusCode)
+		}
+
+		if tt.status == http.StatusOK && string(resp.Body) != tt.body {
+			t.Fatalf("%s: expected body %q, got %q", tt.name, tt.body, resp.Body)
+		}
+
+		if len(resp.Redirects) != tt.hops {
+			t.Fatalf("%s: expected %d hops, got %+v", tt.name, tt.hops, resp.Redirects)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// LevelSetter 运行时修改日志级别，*tclog.TcLog已实现
+type LevelSetter interface {
+	SetLevel(level string)
+	GetLevel() string
+}
+
+// AdminOptions 管理端口的配置，管理端口只应监听内网地址
+type AdminOptions struct {
+	Addr      string            // 监听地址，如127.0.0.1:6060
+	Logger    LevelSetter       // 设置后可通过/loglevel修改日志级别
+	BuildInfo map[string]string // 额外的构建信息，如通过ldflags注入的版本号
+}
+
+var validLevels = map[string]bool{
+	"debug": true, "info": true, "notice": true,
+	"warn": true, "error": true, "fatal": true,
+}
+
+// AdminHandler 返回管理端口的handler:
+// /debug/pprof/ pprof性能分析，/buildinfo 构建信息，/loglevel 查看(GET)、修改(PUT ?level=warn)日志级别
+func AdminHandler(opts AdminOptions) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, Envelope[map[string]string]{
+			Code:   SUCCESS,
+			Msg:    "OK",
+			Result: buildInfo(opts.BuildInfo),
+		})
+	})
+
+	mux.HandleFunc("/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		if opts.Logger == nil {
+			writeAdminJSON(w, http.StatusNotFound, errorEnvelope{Code: SERVER_ERR, Msg: "未设置日志"})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			level := strings.ToLower(r.FormValue("level"))
+			if !validLevels[level] {
+				writeAdminJSON(w, http.StatusBadRequest, errorEnvelope{Code: SERVER_ERR, Msg: "不支持的日志级别: " + level})
+				return
+			}
+			opts.Logger.SetLevel(level)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		writeAdminJSON(w, http.StatusOK, Envelope[string]{Code: SUCCESS, Msg: "OK", Result: opts.Logger.GetLevel()})
+	})
+
+	return mux
+}
+
+func buildInfo(extra map[string]string) map[string]string {
+	info := map[string]string{
+		"go_version": runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info["path"] = bi.Main.Path
+		info["version"] = bi.Main.Version
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				info[setting.Key] = setting.Value
+			}
+		}
+	}
+
+	for key, value := range extra {
+		info[key] = value
+	}
+
+	return info
+}
+
+func writeAdminJSON(w http.ResponseWriter, httpCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(httpCode)
+	json.NewEncoder(w).Encode(v)
+}
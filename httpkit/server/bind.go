@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// DefaultLocale 请求未通过Accept-Language指定支持的语言时使用的语言
+var DefaultLocale = LocaleZh
+
+var emptyBodyMsg = map[string]string{
+	LocaleZh: "请求体为空",
+	LocaleEn: "request body is empty",
+}
+
+// defaultMultipartMemory 与gin默认的MaxMultipartMemory一致
+const defaultMultipartMemory = 32 << 20
+
+// FieldError 单个字段的校验错误，field使用json、form或uri标签中的名称
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	validateOnce sync.Once
+	validate     *validator.Validate
+	translators  map[string]ut.Translator
+)
+
+// Validator 返回绑定使用的校验器，与gin一致使用binding标签，可用于注册自定义校验规则
+func Validator() *validator.Validate {
+	validateOnce.Do(initValidator)
+	return validate
+}
+
+func initValidator() {
+	validate = validator.New()
+	validate.SetTagName("binding")
+	validate.RegisterTagNameFunc(fieldName)
+
+	uni := ut.New(zh.New(), zh.New(), en.New())
+	zhTrans, _ := uni.GetTranslator(LocaleZh)
+	enTrans, _ := uni.GetTranslator(LocaleEn)
+	zhTranslations.RegisterDefaultTranslations(validate, zhTrans)
+	enTranslations.RegisterDefaultTranslations(validate, enTrans)
+
+	translators = map[string]ut.Translator{
+		LocaleZh: zhTrans,
+		LocaleEn: enTrans,
+	}
+}
+
+// fieldName 错误信息中的字段名依次使用json、form、uri标签，与客户端看到的参数名一致
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			break
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+// RequestLocale 根据Accept-Language选择错误信息的语言，只支持zh和en
+func RequestLocale(c *gin.Context) string {
+	locale, best := DefaultLocale, 0.0
+
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if lang != LocaleZh && lang != LocaleEn {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		if q > best {
+			locale, best = lang, q
+		}
+	}
+
+	return locale
+}
+
+// Bind 根据请求方法和Content-Type选择绑定方式: GET、DELETE及无body的请求绑定query，
+// JSON绑定body，表单绑定form。失败时返回错误响应并中止后续handler，ok为false
+//
+//	req, ok := server.Bind[CreateReq](c)
+//	if !ok {
+//		return
+//	}
+func Bind[T any](c *gin.Context) (T, bool) {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodDelete || c.Request.ContentLength == 0 {
+		return BindQuery[T](c)
+	}
+
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		return BindForm[T](c)
+	default:
+		return BindJSON[T](c)
+	}
+}
+
+// BindJSON 将JSON body解析到T并校验
+func BindJSON[T any](c *gin.Context) (T, bool) {
+	var v T
+
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		failBind(c, http.StatusBadRequest, JSON_UNMARSHAL, emptyBodyMsg[RequestLocale(c)])
+		return v, false
+	}
+
+	decoder := json.NewDecoder(c.Request.Body)
+	if binding.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if binding.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(&v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			failBind(c, http.StatusRequestEntityTooLarge, HTTP_BODY_TOO_LARGE, err.Error())
+		} else {
+			failBind(c, http.StatusBadRequest, JSON_UNMARSHAL, err.Error())
+		}
+		return v, false
+	}
+
+	return v, validateBound(c, &v)
+}
+
+// BindQuery 将query参数按form标签解析到T并校验
+func BindQuery[T any](c *gin.Context) (T, bool) {
+	return bindValues[T](c, c.Request.URL.Query(), "form")
+}
+
+// BindForm 将query及表单参数按form标签解析到T并校验，支持multipart表单中的普通字段
+func BindForm[T any](c *gin.Context) (T, bool) {
+	var v T
+
+	if err := c.Request.ParseMultipartForm(defaultMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			failBind(c, http.StatusRequestEntityTooLarge, HTTP_BODY_TOO_LARGE, err.Error())
+		} else {
+			failBind(c, http.StatusBadRequest, HTTP_BODY_ERR, err.Error())
+		}
+		return v, false
+	}
+
+	return bindValues[T](c, c.Request.Form, "form")
+}
+
+// BindURI 将路由参数按uri标签解析到T并校验
+func BindURI[T any](c *gin.Context) (T, bool) {
+	values := make(map[string][]string, len(c.Params))
+	for _, param := range c.Params {
+		values[param.Key] = []string{param.Value}
+	}
+
+	return bindValues[T](c, values, "uri")
+}
+
+func bindValues[T any](c *gin.Context, values map[string][]string, tag string) (T, bool) {
+	var v T
+
+	if err := binding.MapFormWithTag(&v, values, tag); err != nil {
+		failBind(c, http.StatusBadRequest, VALIDATION_ERR, err.Error())
+		return v, false
+	}
+
+	return v, validateBound(c, &v)
+}
+
+// validateBound 校验解析后的结构体，非结构体类型(如map)不校验
+func validateBound(c *gin.Context, v any) bool {
+	if reflect.TypeOf(v).Elem().Kind() != reflect.Struct {
+		return true
+	}
+
+	err := Validator().Struct(v)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		failBind(c, http.StatusBadRequest, VALIDATION_ERR, err.Error())
+		return false
+	}
+
+	locale := RequestLocale(c)
+	trans := translators[locale]
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	msgs := make([]string, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		field := fe.Namespace()
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+
+		msg := fe.Translate(trans)
+		fields = append(fields, FieldError{
+			Field:   field,
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: msg,
+		})
+		msgs = append(msgs, msg)
+	}
+
+	c.AbortWithStatusJSON(http.StatusBadRequest, Envelope[[]FieldError]{
+		Code:   VALIDATION_ERR,
+		Msg:    DefaultCatalog.FormatLocale(locale, VALIDATION_ERR, strings.Join(msgs, "; ")),
+		Result: fields,
+	})
+	return false
+}
+
+// failBind 与Fail相同，错误信息前缀使用请求的语言
+func failBind(c *gin.Context, httpCode, code int, msg string) {
+	c.AbortWithStatusJSON(httpCode, errorEnvelope{
+		Code: code,
+		Msg:  DefaultCatalog.FormatLocale(RequestLocale(c), code, msg),
+	})
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createUserReq struct {
+	Name  string   `json:"name" form:"name" binding:"required"`
+	Age   int      `json:"age" form:"age" binding:"gte=1,lte=150"`
+	Email string   `json:"email" form:"email" binding:"omitempty,email"`
+	Tags  []string `json:"tags" form:"tags" binding:"max=2"`
+}
+
+type userURI struct {
+	ID int `uri:"id" binding:"required,gt=0"`
+}
+
+func TestBind(t *testing.T) {
+	router := gin.New()
+	router.POST("/users", func(c *gin.Context) {
+		req, ok := Bind[createUserReq](c)
+		if !ok {
+			return
+		}
+		OK(c, req)
+	})
+	router.GET("/users", func(c *gin.Context) {
+		req, ok := BindQuery[createUserReq](c)
+		if !ok {
+			return
+		}
+		OK(c, req)
+	})
+	router.GET("/users/:id", func(c *gin.Context) {
+		req, ok := BindURI[userURI](c)
+		if !ok {
+			return
+		}
+		OK(c, req.ID)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		lang        string
+		httpCode    int
+		code        int
+		fields      []string
+		contains    string
+	}{
+		{
+			name:        "json ok",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/json",
+			body:        `{"name":"tom","age":18}`,
+			httpCode:    http.StatusOK,
+			contains:    `"name":"tom"`,
+		},
+		{
+			name:        "json validation zh",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/json",
+			body:        `{"age":200,"email":"x","tags":["a","b","c"]}`,
+			httpCode:    http.StatusBadRequest,
+			code:        VALIDATION_ERR,
+			fields:      []string{"name", "age", "email", "tags"},
+			contains:    `[参数校验失败]: name为必填字段`,
+		},
+		{
+			name:        "json validation en",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/json",
+			body:        `{"age":18}`,
+			lang:        "en-US,en;q=0.9,zh;q=0.8",
+			httpCode:    http.StatusBadRequest,
+			code:        VALIDATION_ERR,
+			fields:      []string{"name"},
+			contains:    `[validation failed]: name is a required field`,
+		},
+		{
+			name:        "prefer zh by quality",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/json",
+			body:        `{"age":18}`,
+			lang:        "en;q=0.5,zh-CN",
+			httpCode:    http.StatusBadRequest,
+			code:        VALIDATION_ERR,
+			fields:      []string{"name"},
+			contains:    `name为必填字段`,
+		},
+		{
+			name:        "malformed json",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/json",
+			body:        `{"name":`,
+			httpCode:    http.StatusBadRequest,
+			code:        JSON_UNMARSHAL,
+			contains:    `[JSON 反序列化异常]: `,
+		},
+		{
+			name:        "form",
+			method:      http.MethodPost,
+			path:        "/users",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=jerry&age=0",
+			httpCode:    http.StatusBadRequest,
+			code:        VALIDATION_ERR,
+			fields:      []string{"age"},
+			contains:    `age必须大于或等于1`,
+		},
+		{name: "query ok", method: http.MethodGet, path: "/users?name=tom&age=3&tags=a", httpCode: http.StatusOK, contains: `"tags":["a"]`},
+		{name: "query type error", method: http.MethodGet, path: "/users?name=tom&age=x", httpCode: http.StatusBadRequest, code: VALIDATION_ERR},
+		{name: "uri ok", method: http.MethodGet, path: "/users/7", httpCode: http.StatusOK, contains: `"result":7`},
+		{
+			name:     "uri validation",
+			method:   http.MethodGet,
+			path:     "/users/-3",
+			httpCode: http.StatusBadRequest,
+			code:     VALIDATION_ERR,
+			fields:   []string{"id"},
+			contains: `id必须大于0`,
+		},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.lang != "" {
+			req.Header.Set("Accept-Language", tt.lang)
+		}
+
+		w := serve(router, req)
+		if w.Code != tt.httpCode || !strings.Contains(w.Body.String(), tt.contains) {
+			t.Fatalf("%s: expected %d containing %q, got %d %s", tt.name, tt.httpCode, tt.contains, w.Code, w.Body.String())
+		}
+
+		var env Envelope[[]FieldError]
+		if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil && tt.code != SUCCESS {
+			t.Fatalf("%s: decode envelope failed, %v", tt.name, err)
+		}
+		if env.Code != tt.code {
+			t.Fatalf("%s: expected code %d, got %d", tt.name, tt.code, env.Code)
+		}
+
+		if tt.code != VALIDATION_ERR || tt.fields == nil {
+			continue
+		}
+		if len(env.Result) != len(tt.fields) {
+			t.Fatalf("%s: expected fields %v, got %+v", tt.name, tt.fields, env.Result)
+		}
+		for i, field := range tt.fields {
+			if env.Result[i].Field != field || env.Result[i].Message == "" {
+				t.Fatalf("%s: expected field %s, got %+v", tt.name, field, env.Result[i])
+			}
+		}
+	}
+}
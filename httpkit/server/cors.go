@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSOptions 跨域配置
+type CORSOptions struct {
+	AllowOrigins     []string // 允许的Origin，"*"表示全部，"*.example.com"匹配子域名
+	AllowMethods     []string // 默认GET、POST、PUT、PATCH、DELETE、HEAD
+	AllowHeaders     []string // 为空时允许预检请求中的全部请求头
+	ExposeHeaders    []string
+	AllowCredentials bool          // 只对列出的Origin生效，通过"*"匹配的Origin不允许携带凭证
+	MaxAge           time.Duration // 预检结果的缓存时间
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+// CORS 处理跨域请求，预检请求直接返回204
+func CORS(opts CORSOptions) gin.HandlerFunc {
+	if len(opts.AllowMethods) == 0 {
+		opts.AllowMethods = defaultCORSMethods
+	}
+
+	allowMethods := strings.Join(opts.AllowMethods, ", ")
+	allowHeaders := strings.Join(opts.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opts.ExposeHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		allowOrigin, ok := opts.matchOrigin(origin)
+		if !ok {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("Access-Control-Allow-Origin", allowOrigin)
+		if opts.AllowCredentials && allowOrigin != "*" {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// 预检请求
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+
+			if allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if reqHeaders := c.GetHeader("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge/time.Second)))
+			}
+
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+
+		c.Next()
+	}
+}
+
+// matchOrigin 返回Access-Control-Allow-Origin的值，只回显列出的Origin，
+// "*"匹配时返回"*"，浏览器不会为其携带凭证
+func (opts *CORSOptions) matchOrigin(origin string) (string, bool) {
+	wildcard := false
+	for _, allowed := range opts.AllowOrigins {
+		switch {
+		case allowed == "*":
+			wildcard = true
+		case strings.HasPrefix(allowed, "*."):
+			if host := originHost(origin); strings.HasSuffix(host, allowed[1:]) {
+				return origin, true
+			}
+		case strings.EqualFold(allowed, origin):
+			return origin, true
+		}
+	}
+
+	if wildcard {
+		return "*", true
+	}
+	return "", false
+}
+
+func originHost(origin string) string {
+	if i := strings.Index(origin, "://"); i >= 0 {
+		origin = origin[i+3:]
+	}
+
+	if i := strings.LastIndexByte(origin, ':'); i >= 0 {
+		origin = origin[:i]
+	}
+
+	return strings.ToLower(origin)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 内置的错误码，业务错误码请使用其他区间并通过Register注册
+const (
+	SUCCESS = 0
+
+	JSON_UNMARSHAL      = 1000
+	HTTP_BODY_ERR       = 1001
+	HTTP_BODY_TOO_LARGE = 1002
+	VALIDATION_ERR      = 1003
+
+	SERVER_ERR   = 2000
+	RPC_ERR      = 2001
+	INTERNAL_ERR = 2002
+	TIMEOUT_ERR  = 2003
+	RATE_LIMITED = 2004
+	SERVER_BUSY  = 2005
+	NOT_READY    = 2006
+)
+
+// 错误信息支持的语言，Register注册的前缀为LocaleZh
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+)
+
+// Catalog 错误码与错误信息前缀的对应关系，并发安全
+type Catalog struct {
+	lock    sync.RWMutex
+	msgs    map[int]string
+	locales map[string]map[int]string
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{
+		msgs:    make(map[int]string),
+		locales: make(map[string]map[int]string),
+	}
+}
+
+// DefaultCatalog 响应函数使用的错误码表
+var DefaultCatalog = NewCatalog()
+
+func init() {
+	DefaultCatalog.MustRegister(JSON_UNMARSHAL, "[JSON 反序列化异常]: ")
+	DefaultCatalog.MustRegister(HTTP_BODY_ERR, "[HTTP BODY读取异常]: ")
+	DefaultCatalog.MustRegister(HTTP_BODY_TOO_LARGE, "[HTTP BODY超出大小限制]: ")
+	DefaultCatalog.MustRegister(VALIDATION_ERR, "[参数校验失败]: ")
+	DefaultCatalog.MustRegister(SERVER_ERR, "[选择后端节点异常]: ")
+	DefaultCatalog.MustRegister(RPC_ERR, "[远端服务器调用出错]: ")
+	DefaultCatalog.MustRegister(INTERNAL_ERR, "[服务内部错误]: ")
+	DefaultCatalog.MustRegister(TIMEOUT_ERR, "[请求处理超时]: ")
+	DefaultCatalog.MustRegister(RATE_LIMITED, "[请求过于频繁]: ")
+	DefaultCatalog.MustRegister(SERVER_BUSY, "[服务繁忙]: ")
+	DefaultCatalog.MustRegister(NOT_READY, "[服务未就绪]: ")
+
+	for code, msg := range map[int]string{
+		JSON_UNMARSHAL:      "[JSON unmarshal error]: ",
+		HTTP_BODY_ERR:       "[HTTP body read error]: ",
+		HTTP_BODY_TOO_LARGE: "[HTTP body too large]: ",
+		VALIDATION_ERR:      "[validation failed]: ",
+		SERVER_ERR:          "[backend selection error]: ",
+		RPC_ERR:             "[remote call error]: ",
+		INTERNAL_ERR:        "[internal server error]: ",
+		TIMEOUT_ERR:         "[request timeout]: ",
+		RATE_LIMITED:        "[too many requests]: ",
+		SERVER_BUSY:         "[server busy]: ",
+		NOT_READY:           "[server not ready]: ",
+	} {
+		DefaultCatalog.RegisterLocale(LocaleEn, code, msg)
+	}
+}
+
+// Register 注册错误码，错误码已存在时返回错误
+func (c *Catalog) Register(code int, msg string) error {
+	if code == SUCCESS {
+		return fmt.Errorf("错误码%d为成功的保留码", code)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if old, ok := c.msgs[code]; ok {
+		return fmt.Errorf("错误码%d已注册为[%s]", code, old)
+	}
+
+	c.msgs[code] = msg
+	return nil
+}
+
+// MustRegister 与Register相同，出错时panic，适用于init中注册
+func (c *Catalog) MustRegister(code int, msg string) {
+	if err := c.Register(code, msg); err != nil {
+		panic(err)
+	}
+}
+
+// Message 返回错误码对应的错误信息前缀
+func (c *Catalog) Message(code int) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	msg, ok := c.msgs[code]
+	return msg, ok
+}
+
+// RegisterLocale 注册错误码在其他语言下的前缀，已存在时覆盖
+func (c *Catalog) RegisterLocale(locale string, code int, msg string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	msgs, ok := c.locales[locale]
+	if !ok {
+		msgs = make(map[int]string)
+		c.locales[locale] = msgs
+	}
+	msgs[code] = msg
+}
+
+// MessageLocale 返回错误码在locale下的前缀，未注册该语言时使用Register注册的前缀
+func (c *Catalog) MessageLocale(locale string, code int) (string, bool) {
+	c.lock.RLock()
+	msg, ok := c.locales[locale][code]
+	c.lock.RUnlock()
+
+	if ok {
+		return msg, true
+	}
+
+	return c.Message(code)
+}
+
+// Codes 返回已注册的错误码，按从小到大排序
+func (c *Catalog) Codes() []int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	codes := make([]int, 0, len(c.msgs))
+	for code := range c.msgs {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	return codes
+}
+
+// Format 错误信息前加上错误码对应的前缀，未注册的错误码原样返回
+func (c *Catalog) Format(code int, msg string) string {
+	if prefix, ok := c.Message(code); ok {
+		return prefix + msg
+	}
+
+	return msg
+}
+
+// FormatLocale 与Format相同，使用locale下的前缀
+func (c *Catalog) FormatLocale(locale string, code int, msg string) string {
+	if prefix, ok := c.MessageLocale(locale, code); ok {
+		return prefix + msg
+	}
+
+	return msg
+}
+
+// Register 在DefaultCatalog中注册错误码
+func Register(code int, msg string) error {
+	return DefaultCatalog.Register(code, msg)
+}
+
+// MustRegister 在DefaultCatalog中注册错误码，出错时panic
+func MustRegister(code int, msg string) {
+	DefaultCatalog.MustRegister(code, msg)
+}
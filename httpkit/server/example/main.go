@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xkeyideal/gokit/httpkit/server"
+)
+
+type TestData struct {
+	Name string `json:"name"`
+}
+
+func main() {
+	srv := server.New(server.Options{
+		Addr:         "0.0.0.0:12745",
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		DrainDelay:   5 * time.Second,
+	})
+
+	srv.Router().POST("/test", func(c *gin.Context) {
+		log.Println("req.headers", c.Request.Header)
+
+		bytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			server.FailErr(c, http.StatusBadRequest, server.HTTP_BODY_ERR, err)
+			return
+		}
+
+		server.OK(c, TestData{Name: string(bytes)})
+	})
+
+	if err := srv.Run(); err != nil {
+		log.Fatalln("server exit error", err)
+	}
+}
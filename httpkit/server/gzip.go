@@ -0,0 +1,124 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gzip 客户端支持gzip时压缩响应，已设置Content-Encoding或SSE响应不压缩
+// level为gzip.DefaultCompression等压缩级别
+func Gzip(level int) gin.HandlerFunc {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+
+	pool := &sync.Pool{
+		New: func() interface{} {
+			gz, _ := gzip.NewWriterLevel(nil, level)
+			return gz
+		},
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !acceptGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		w := &gzipWriter{ResponseWriter: c.Writer, pool: pool}
+		c.Writer = w
+		defer w.close()
+
+		c.Next()
+	}
+}
+
+func acceptGzip(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(encoding), "gzip") {
+			continue
+		}
+
+		// gzip;q=0 表示不接受
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+
+	return false
+}
+
+type gzipWriter struct {
+	gin.ResponseWriter
+	pool *sync.Pool
+
+	decided bool
+	gz      *gzip.Writer
+}
+
+// decide 首次写body、发送响应头或Flush时根据响应头决定是否压缩，响应头发出后不能再修改
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+
+	return w.gz.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow 响应头发出前决定是否压缩，保证Content-Encoding与body一致
+func (w *gzipWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *gzipWriter) Flush() {
+	w.decide()
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+
+	w.gz.Close()
+	w.gz.Reset(nil)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+
+	DefaultCheckTimeout  = 2 * time.Second
+	DefaultCheckCacheTTL = 5 * time.Second
+)
+
+// CheckFunc 依赖检查，返回nil表示依赖可用，需响应ctx的超时
+type CheckFunc func(ctx context.Context) error
+
+// CheckOptions 依赖检查的配置
+type CheckOptions struct {
+	Timeout  time.Duration // 单次检查的超时时间，默认2s
+	CacheTTL time.Duration // 检查结果的缓存时间，默认5s，避免探针频繁访问依赖
+}
+
+// CheckResult 依赖检查的结果
+type CheckResult struct {
+	Status    string    `json:"status"` // ok或fail
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+	opts CheckOptions
+
+	lock   sync.Mutex
+	result CheckResult
+	err    error
+}
+
+// run 返回缓存的结果，缓存过期时执行检查，同一检查不会并发执行
+func (c *check) run(ctx context.Context) (CheckResult, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.result.CheckedAt.IsZero() && time.Since(c.result.CheckedAt) < c.opts.CacheTTL {
+		return c.result, c.err
+	}
+
+	// 检查结果会缓存给所有探针，不使用触发检查的请求的取消，只受Timeout限制，
+	// 该请求超时或断开时不会缓存失败的结果
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("检查超时: %w", ctx.Err())
+	}
+
+	c.err = err
+	c.result = CheckResult{
+		Status:    "ok",
+		Latency:   time.Since(start).String(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		c.result.Status = "fail"
+		c.result.Error = err.Error()
+	}
+
+	return c.result, c.err
+}
+
+// health 就绪检查注册表
+type health struct {
+	lock   sync.RWMutex
+	checks []*check
+}
+
+func (h *health) register(name string, fn CheckFunc, opts CheckOptions) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCheckTimeout
+	}
+
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = DefaultCheckCacheTTL
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, c := range h.checks {
+		if c.name == name {
+			return fmt.Errorf("依赖检查%s已注册", name)
+		}
+	}
+
+	h.checks = append(h.checks, &check{name: name, fn: fn, opts: opts})
+	return nil
+}
+
+// runAll 并发执行所有检查，全部通过时返回nil
+func (h *health) runAll(ctx context.Context) (map[string]CheckResult, error) {
+	h.lock.RLock()
+	checks := append([]*check(nil), h.checks...)
+	h.lock.RUnlock()
+
+	results := make(map[string]CheckResult, len(checks))
+	errs := make([]error, len(checks))
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+
+			result, err := c.run(ctx)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", c.name, err)
+			}
+
+			lock.Lock()
+			results[c.name] = result
+			lock.Unlock()
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results, errors.Join(errs...)
+}
+
+// RegisterCheck 注册就绪检查，如mongo ping、etcd session、nsq producer状态，name不能重复
+func (s *Server) RegisterCheck(name string, fn CheckFunc, opts CheckOptions) error {
+	return s.health.register(name, fn, opts)
+}
+
+func (s *Server) mountHealth() {
+	// 存活检查只表示进程可以处理请求，不检查依赖
+	s.router.GET(HealthzPath, func(c *gin.Context) {
+		OK(c, "ok")
+	})
+
+	s.router.GET(ReadyzPath, func(c *gin.Context) {
+		if s.Draining() {
+			Fail(c, http.StatusServiceUnavailable, NOT_READY, "server draining")
+			return
+		}
+
+		results, err := s.health.runAll(c.Request.Context())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, Envelope[map[string]CheckResult]{
+				Code:   NOT_READY,
+				Msg:    DefaultCatalog.Format(NOT_READY, err.Error()),
+				Result: results,
+			})
+			return
+		}
+
+		OK(c, results)
+	})
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xkeyideal/gokit/tclog"
+)
+
+func TestReadyz(t *testing.T) {
+	var mongoCalls atomic.Int32
+	mongoErr := atomic.Pointer[error]{}
+
+	srv := New(Options{})
+	srv.RegisterCheck("mongo", func(ctx context.Context) error {
+		mongoCalls.Add(1)
+		if err := mongoErr.Load(); err != nil {
+			return *err
+		}
+		return nil
+	}, CheckOptions{CacheTTL: time.Hour})
+	if err := srv.RegisterCheck("mongo", func(ctx context.Context) error { return nil }, CheckOptions{}); err == nil {
+		t.Fatalf("duplicate check should be rejected")
+	}
+
+	tests := []struct {
+		name     string
+		setup    func()
+		path     string
+		httpCode int
+		contains string
+	}{
+		{name: "healthz", path: HealthzPath, httpCode: http.StatusOK, contains: `"result":"ok"`},
+		{name: "ready", path: ReadyzPath, httpCode: http.StatusOK, contains: `"mongo":{"status":"ok"`},
+		{
+			name: "cached result",
+			setup: func() {
+				err := errors.New("no reachable servers")
+				mongoErr.Store(&err)
+			},
+			path:     ReadyzPath,
+			httpCode: http.StatusOK,
+		},
+		{
+			name: "failing check",
+			setup: func() {
+				srv.RegisterCheck("etcd", func(ctx context.Context) error {
+					return errors.New("session expired")
+				}, CheckOptions{})
+			},
+			path:     ReadyzPath,
+			httpCode: http.StatusServiceUnavailable,
+			contains: `"etcd":{"status":"fail","error":"session expired"`,
+		},
+		{
+			name: "timeout",
+			setup: func() {
+				srv.RegisterCheck("nsq", func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				}, CheckOptions{Timeout: 20 * time.Millisecond})
+			},
+			path:     ReadyzPath,
+			httpCode: http.StatusServiceUnavailable,
+			contains: `检查超时`,
+		},
+		{
+			name:     "draining",
+			setup:    func() { srv.draining.Store(true) },
+			path:     ReadyzPath,
+			httpCode: http.StatusServiceUnavailable,
+			contains: `server draining`,
+		},
+		{name: "healthz while draining", path: HealthzPath, httpCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if tt.setup != nil {
+			tt.setup()
+		}
+
+		w := serve(srv.Router(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.httpCode || !strings.Contains(w.Body.String(), tt.contains) {
+			t.Fatalf("%s: expected %d containing %q, got %d %s", tt.name, tt.httpCode, tt.contains, w.Code, w.Body.String())
+		}
+	}
+
+	if n := mongoCalls.Load(); n != 1 {
+		t.Fatalf("check result should be cached, called %d times", n)
+	}
+}
+
+func TestCheckCallerCanceled(t *testing.T) {
+	var calls atomic.Int32
+	c := &check{
+		name: "slow",
+		fn: func(ctx context.Context) error {
+			calls.Add(1)
+			select {
+			case <-time.After(20 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		opts: CheckOptions{Timeout: time.Second, CacheTTL: time.Hour},
+	}
+
+	// 触发检查的探针已断开，检查仍按自身的超时执行
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result, err := c.run(ctx); err != nil || result.Status != "ok" {
+		t.Fatalf("check should not be canceled by caller, %+v %v", result, err)
+	}
+
+	if result, err := c.run(context.Background()); err != nil || result.Status != "ok" || calls.Load() != 1 {
+		t.Fatalf("expected cached ok result, %+v %v, calls %d", result, err, calls.Load())
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	logger, err := tclog.NewTcLog(t.TempDir(), "admin_test", "info")
+	if err != nil {
+		t.Fatalf("create logger failed, %v", err)
+	}
+	defer logger.Close()
+
+	srv := New(Options{
+		Admin: &AdminOptions{
+			Addr:      "127.0.0.1:0",
+			Logger:    logger,
+			BuildInfo: map[string]string{"version": "v1.2.3"},
+		},
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ctx, ln)
+	}()
+	defer func() {
+		cancel()
+		if err := <-serveErr; err != nil {
+			t.Fatalf("serve returned error, %v", err)
+		}
+	}()
+
+	// 等待管理端口启动
+	deadline := time.Now().Add(time.Second)
+	for srv.AdminAddr() == "127.0.0.1:0" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	base := "http://" + srv.AdminAddr()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		form     url.Values
+		httpCode int
+		contains string
+	}{
+		{name: "pprof", method: http.MethodGet, path: "/debug/pprof/", httpCode: http.StatusOK, contains: "goroutine"},
+		{name: "buildinfo", method: http.MethodGet, path: "/buildinfo", httpCode: http.StatusOK, contains: `"version":"v1.2.3"`},
+		{name: "get level", method: http.MethodGet, path: "/loglevel", httpCode: http.StatusOK, contains: `"result":"INFO"`},
+		{name: "set level", method: http.MethodPut, path: "/loglevel", form: url.Values{"level": {"error"}}, httpCode: http.StatusOK, contains: `"result":"ERROR"`},
+		{name: "invalid level", method: http.MethodPut, path: "/loglevel", form: url.Values{"level": {"trace"}}, httpCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, base+tt.path, strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed, %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.httpCode || !strings.Contains(string(body), tt.contains) {
+			t.Fatalf("%s: expected %d containing %q, got %d %s", tt.name, tt.httpCode, tt.contains, resp.StatusCode, body)
+		}
+	}
+
+	if logger.GetLevel() != "ERROR" {
+		t.Fatalf("log level should be switched, got %s", logger.GetLevel())
+	}
+
+	var env Envelope[map[string]string]
+	resp, err := http.Get(base + "/buildinfo")
+	if err != nil {
+		t.Fatalf("buildinfo failed, %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil || env.Result["go_version"] == "" {
+		t.Fatalf("unexpected buildinfo %v %+v", err, env)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xkeyideal/gokit/tclog"
+)
+
+// Logger 中间件使用的日志接口，*tclog.TcLog、*tclog.TcLogField均已实现，
+// v末尾可以跟tclog.Field作为结构化字段
+type Logger interface {
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+}
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	requestIDKey    = "httpkit.request_id"
+	maxRequestIDLen = 128
+)
+
+type requestIDCtxKey struct{}
+
+// RequestID 使用请求头X-Request-ID作为请求id，不存在或不合法时生成新的id
+// 请求id写入响应头，并保存在gin.Context及c.Request.Context()中
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDCtxKey{}, id))
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// GetRequestID 返回RequestID中间件设置的请求id
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// RequestIDFromContext 从context中获取请求id，调用下游服务时可通过RequestIDHeader透传
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Recovery 捕获handler的panic并返回500及统一的错误响应，logger不为nil时记录panic的堆栈
+func Recovery(logger Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			// 客户端断开连接时无法再写响应
+			if brokenPipe(err) {
+				if logger != nil {
+					logger.Warn("[recovery] %s %s connection broken: %v", c.Request.Method, c.Request.URL.Path, err)
+				}
+				c.Abort()
+				return
+			}
+
+			if logger != nil {
+				logger.Error("[recovery] %s %s request_id=%s panic: %v\n%s",
+					c.Request.Method, c.Request.URL.Path, GetRequestID(c), err, debug.Stack())
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			Fail(c, http.StatusInternalServerError, INTERNAL_ERR, http.StatusText(http.StatusInternalServerError))
+		}()
+
+		c.Next()
+	}
+}
+
+func brokenPipe(v interface{}) bool {
+	err, ok := v.(error)
+	if !ok {
+		return false
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+
+	var syscallErr *os.SyscallError
+	if !errors.As(opErr, &syscallErr) {
+		return false
+	}
+
+	msg := strings.ToLower(syscallErr.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// AccessLog 请求结束后以结构化字段记录访问日志，5xx使用Error级别，4xx使用Warn级别，其余使用Info级别
+func AccessLog(logger Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []interface{}{
+			tclog.String("method", c.Request.Method),
+			tclog.String("path", path),
+			tclog.String("query", query),
+			tclog.Int("status", status),
+			tclog.Duration("latency", time.Since(start)),
+			tclog.String("ip", c.ClientIP()),
+			tclog.Int("size", c.Writer.Size()),
+			tclog.String("request_id", GetRequestID(c)),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, tclog.String("errors", c.Errors.String()))
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			logger.Error("access", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warn("access", fields...)
+		default:
+			logger.Info("access", fields...)
+		}
+	}
+}
+
+// Timeout 为单个路由设置处理超时，c.Request.Context()在超时后取消
+// handler需要响应context的取消，超时且未写响应时返回504
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			Fail(c, http.StatusGatewayTimeout, TIMEOUT_ERR, fmt.Sprintf("超过%s", d))
+		}
+	}
+}
+
+// BodyLimit 限制请求body的大小，超过limit字节时返回413
+// Content-Length未知时，handler读取body超过limit会得到*http.MaxBytesError
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			Fail(c, http.StatusRequestEntityTooLarge, HTTP_BODY_TOO_LARGE, fmt.Sprintf("最大%d字节", limit))
+			return
+		}
+
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+
+		c.Next()
+	}
+}
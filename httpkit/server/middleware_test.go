@@ -0,0 +1,393 @@
+package server
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xkeyideal/gokit/tclog"
+)
+
+type memLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+// log 与tclog相同，v末尾的Field以key=value的形式输出在消息之后
+func (l *memLogger) log(level, format string, v ...interface{}) {
+	n := len(v)
+	for n > 0 {
+		if _, ok := v[n-1].(tclog.Field); !ok {
+			break
+		}
+		n--
+	}
+
+	line := level + " " + format
+	if n > 0 || n == len(v) {
+		line = level + " " + fmt.Sprintf(format, v[:n]...)
+	}
+	for _, f := range v[n:] {
+		field := f.(tclog.Field)
+		line += fmt.Sprintf(" %s=%v", field.Key, field.Value())
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (l *memLogger) Info(format string, v ...interface{})  { l.log("INFO", format, v...) }
+func (l *memLogger) Warn(format string, v ...interface{})  { l.log("WARN", format, v...) }
+func (l *memLogger) Error(format string, v ...interface{}) { l.log("ERROR", format, v...) }
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestID(c)+"|"+RequestIDFromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "generate", incoming: "", keep: false},
+		{name: "propagate", incoming: "abc-123", keep: true},
+		{name: "invalid chars", incoming: "bad id\n", keep: false},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLen+1), keep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := serve(router, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.keep != (id == tt.incoming) || id == "" {
+				t.Fatalf("unexpected request id %q for incoming %q", id, tt.incoming)
+			}
+			if w.Body.String() != id+"|"+id {
+				t.Fatalf("request id not stored, %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  gin.HandlerFunc
+		httpCode int
+		body     string
+		logged   bool
+	}{
+		{
+			name:     "panic",
+			handler:  func(c *gin.Context) { panic("boom") },
+			httpCode: http.StatusInternalServerError,
+			body:     `{"code":2002,"msg":"[服务内部错误]: Internal Server Error"}`,
+			logged:   true,
+		},
+		{
+			name: "panic after write",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusOK, "partial")
+				panic("boom")
+			},
+			httpCode: http.StatusOK,
+			body:     "partial",
+			logged:   true,
+		},
+		{
+			name:     "no panic",
+			handler:  func(c *gin.Context) { OK(c, 1) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &memLogger{}
+			router := gin.New()
+			router.Use(Recovery(logger))
+			router.GET("/", tt.handler)
+
+			w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.httpCode || w.Body.String() != tt.body {
+				t.Fatalf("expected %d %s, got %d %s", tt.httpCode, tt.body, w.Code, w.Body.String())
+			}
+
+			if logged := len(logger.lines) > 0 && strings.Contains(logger.lines[0], "panic: boom"); logged != tt.logged {
+				t.Fatalf("unexpected log %v", logger.lines)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		level  string
+	}{
+		{name: "ok", status: http.StatusOK, level: "INFO"},
+		{name: "client error", status: http.StatusNotFound, level: "WARN"},
+		{name: "server error", status: http.StatusBadGateway, level: "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &memLogger{}
+			router := gin.New()
+			router.Use(RequestID(), AccessLog(logger))
+			router.GET("/path", func(c *gin.Context) { c.Status(tt.status) })
+
+			req := httptest.NewRequest(http.MethodGet, "/path?a=1", nil)
+			req.Header.Set(RequestIDHeader, "rid")
+			serve(router, req)
+
+			if len(logger.lines) != 1 {
+				t.Fatalf("expected one access log, got %v", logger.lines)
+			}
+
+			line := logger.lines[0]
+			expected := fmt.Sprintf("%s access method=GET path=/path query=a=1 status=%d latency=", tt.level, tt.status)
+			if !strings.HasPrefix(line, expected) || !strings.Contains(line, "request_id=rid") {
+				t.Fatalf("unexpected access log %q", line)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  gin.HandlerFunc
+		httpCode int
+	}{
+		{
+			name: "timeout",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+			},
+			httpCode: http.StatusGatewayTimeout,
+		},
+		{
+			name: "handler responds after timeout",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.Status(http.StatusServiceUnavailable)
+				c.Writer.WriteHeaderNow()
+			},
+			httpCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "in time",
+			handler:  func(c *gin.Context) { c.Status(http.StatusOK) },
+			httpCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", Timeout(20*time.Millisecond), tt.handler)
+
+			w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.httpCode {
+				t.Fatalf("expected %d, got %d %s", tt.httpCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	opts := CORSOptions{
+		AllowOrigins:     []string{"https://a.com", "*.b.com"},
+		ExposeHeaders:    []string{RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	tests := []struct {
+		name        string
+		opts        CORSOptions
+		method      string
+		origin      string
+		preflight   bool
+		httpCode    int
+		allowOrigin string
+		credentials string
+	}{
+		{name: "no origin", opts: opts, method: http.MethodGet, httpCode: http.StatusOK},
+		{name: "exact origin", opts: opts, method: http.MethodGet, origin: "https://a.com", httpCode: http.StatusOK, allowOrigin: "https://a.com", credentials: "true"},
+		{name: "subdomain", opts: opts, method: http.MethodGet, origin: "https://x.b.com:8443", httpCode: http.StatusOK, allowOrigin: "https://x.b.com:8443", credentials: "true"},
+		{name: "not allowed", opts: opts, method: http.MethodGet, origin: "https://evil.com", httpCode: http.StatusOK},
+		{name: "preflight", opts: opts, method: http.MethodOptions, origin: "https://a.com", preflight: true, httpCode: http.StatusNoContent, allowOrigin: "https://a.com", credentials: "true"},
+		{name: "preflight not allowed", opts: opts, method: http.MethodOptions, origin: "https://evil.com", preflight: true, httpCode: http.StatusForbidden},
+		{name: "wildcard", opts: CORSOptions{AllowOrigins: []string{"*"}}, method: http.MethodGet, origin: "https://any.com", httpCode: http.StatusOK, allowOrigin: "*"},
+		{name: "wildcard with credentials", opts: CORSOptions{AllowOrigins: []string{"*"}, AllowCredentials: true}, method: http.MethodGet, origin: "https://evil.com", httpCode: http.StatusOK, allowOrigin: "*"},
+		{name: "wildcard with listed origin", opts: CORSOptions{AllowOrigins: []string{"*", "https://a.com"}, AllowCredentials: true}, method: http.MethodGet, origin: "https://a.com", httpCode: http.StatusOK, allowOrigin: "https://a.com", credentials: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(CORS(tt.opts))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+				req.Header.Set("Access-Control-Request-Headers", "X-Custom")
+			}
+
+			w := serve(router, req)
+			if w.Code != tt.httpCode || w.Header().Get("Access-Control-Allow-Origin") != tt.allowOrigin {
+				t.Fatalf("expected %d %q, got %d %q", tt.httpCode, tt.allowOrigin, w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+			if w.Header().Get("Access-Control-Allow-Credentials") != tt.credentials {
+				t.Fatalf("expected credentials %q, got %q", tt.credentials, w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+
+			if tt.preflight && tt.allowOrigin != "" {
+				if w.Header().Get("Access-Control-Allow-Headers") != "X-Custom" || w.Header().Get("Access-Control-Max-Age") != "3600" {
+					t.Fatalf("unexpected preflight headers %v", w.Header())
+				}
+			}
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		unknownLength bool
+		httpCode      int
+	}{
+		{name: "within limit", body: "12345", httpCode: http.StatusOK},
+		{name: "content length too large", body: "123456789", httpCode: http.StatusRequestEntityTooLarge},
+		{name: "chunked too large", body: "123456789", unknownLength: true, httpCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(BodyLimit(8))
+			router.POST("/", func(c *gin.Context) {
+				if _, err := io.ReadAll(c.Request.Body); err != nil {
+					FailErr(c, http.StatusBadRequest, HTTP_BODY_ERR, err)
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+
+			w := serve(router, req)
+			if w.Code != tt.httpCode {
+				t.Fatalf("expected %d, got %d %s", tt.httpCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	payload := strings.Repeat("gokit ", 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        gin.HandlerFunc
+		gzipped        bool
+	}{
+		{name: "gzip", acceptEncoding: "gzip, deflate", handler: func(c *gin.Context) { c.String(http.StatusOK, payload) }, gzipped: true},
+		{name: "not accepted", acceptEncoding: "br", handler: func(c *gin.Context) { c.String(http.StatusOK, payload) }},
+		{name: "q=0", acceptEncoding: "gzip;q=0", handler: func(c *gin.Context) { c.String(http.StatusOK, payload) }},
+		{name: "no content", acceptEncoding: "gzip", handler: func(c *gin.Context) { c.Status(http.StatusNoContent) }},
+		{
+			name:           "flush first",
+			acceptEncoding: "gzip",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusOK)
+				c.Writer.Flush()
+				c.Writer.WriteString(payload)
+			},
+			gzipped: true,
+		},
+		{
+			name:           "header first",
+			acceptEncoding: "gzip",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusOK)
+				c.Writer.WriteHeaderNow()
+				c.Writer.WriteString(payload)
+			},
+			gzipped: true,
+		},
+		{
+			name:           "event stream",
+			acceptEncoding: "gzip",
+			handler: func(c *gin.Context) {
+				c.Header("Content-Type", "text/event-stream")
+				c.String(http.StatusOK, payload)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Gzip(gzip.BestSpeed))
+			router.GET("/", tt.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := serve(router, req)
+
+			// 使用响应头发出时的快照，之后再修改的响应头不会发送给客户端
+			if gzipped := w.Result().Header.Get("Content-Encoding") == "gzip"; gzipped != tt.gzipped {
+				t.Fatalf("expected gzipped %v, headers %v", tt.gzipped, w.Result().Header)
+			}
+
+			if !tt.gzipped {
+				return
+			}
+
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("invalid gzip body, %v", err)
+			}
+			body, err := io.ReadAll(gr)
+			if err != nil || string(body) != payload {
+				t.Fatalf("unexpected body %q %v", body, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitOptions 令牌桶限流配置
+type RateLimitOptions struct {
+	Rate    float64                   // 每秒生成的令牌数，Rate及Burst都<=0时不限流
+	Burst   int                       // 桶容量，即允许的突发请求数，默认为Rate向上取整
+	KeyFunc func(*gin.Context) string // 限流的key，默认为客户端IP，返回空字符串时不限流
+	IdleTTL time.Duration             // key空闲多久后回收桶，默认10分钟
+}
+
+// RateLimit 按key使用令牌桶限流，超过限制时返回429及Retry-After
+func RateLimit(opts RateLimitOptions) gin.HandlerFunc {
+	limiter := newRateLimiter(opts)
+	if limiter == nil {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := limiter.keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if wait := limiter.take(key); wait > 0 {
+			reject(c, http.StatusTooManyRequests, RATE_LIMITED, wait)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// reject 设置Retry-After(向上取整到秒)并返回错误响应
+func reject(c *gin.Context, httpCode, code int, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	Fail(c, httpCode, code, "请"+strconv.Itoa(seconds)+"秒后重试")
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	idleTTL time.Duration
+	keyFunc func(*gin.Context) string
+	now     func() time.Time
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// newRateLimiter Rate及Burst都<=0时返回nil，表示不限流
+func newRateLimiter(opts RateLimitOptions) *rateLimiter {
+	if opts.Rate < 0 {
+		opts.Rate = 0
+	}
+
+	if opts.Rate == 0 && opts.Burst <= 0 {
+		return nil
+	}
+
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Ceil(opts.Rate))
+	}
+
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = func(c *gin.Context) string {
+			return c.ClientIP()
+		}
+	}
+
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = 10 * time.Minute
+	}
+
+	return &rateLimiter{
+		rate:    opts.Rate,
+		burst:   float64(opts.Burst),
+		idleTTL: opts.IdleTTL,
+		keyFunc: opts.KeyFunc,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// take 取一个令牌，令牌不足时返回需要等待的时间
+func (l *rateLimiter) take(key string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	if l.rate <= 0 {
+		return time.Second
+	}
+
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep 定期回收空闲的桶，空闲超过IdleTTL的桶已被填满，回收不影响限流结果
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ConcurrencyLimit 限制同时处理的请求数，超过max时立即返回503，max<=0时不限制
+func ConcurrencyLimit(max int) gin.HandlerFunc {
+	if max <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	sem := make(chan struct{}, max)
+
+	return func(c *gin.Context) {
+		select {
+		case sem <- struct{}{}:
+		default:
+			reject(c, http.StatusServiceUnavailable, SERVER_BUSY, time.Second)
+			return
+		}
+		defer func() { <-sem }()
+
+		c.Next()
+	}
+}
+
+// LoadShedOptions 自适应降载配置
+type LoadShedOptions struct {
+	TargetLatency time.Duration // 目标耗时，必须大于0，已完成请求耗时的滑动平均或最早的进行中请求的耗时超过该值时开始拒绝请求
+	MinInFlight   int           // 进行中的请求数低于该值时不拒绝，默认1
+	Decay         float64       // 滑动平均中新样本的权重，默认0.1
+	HalfLife      time.Duration // 没有请求完成时滑动平均每经过HalfLife减半，避免拒绝期间一直无法恢复，默认1s
+	RetryAfter    time.Duration // 默认1s
+}
+
+// LoadShed 已完成请求耗时的滑动平均或最早的进行中请求的耗时超过目标耗时，且仍有请求在处理时，
+// 直接返回503及Retry-After，后端卡住时进行中的请求不会完成，按其耗时同样会拒绝，
+// opts.TargetLatency<=0时panic
+func LoadShed(opts LoadShedOptions) gin.HandlerFunc {
+	shedder := newLoadShedder(opts)
+
+	return func(c *gin.Context) {
+		req, ok := shedder.admit()
+		if !ok {
+			reject(c, http.StatusServiceUnavailable, SERVER_BUSY, shedder.retryAfter)
+			return
+		}
+		defer shedder.done(req)
+
+		c.Next()
+	}
+}
+
+type loadShedder struct {
+	target      time.Duration
+	minInFlight int
+	decay       float64
+	halfLife    time.Duration
+	retryAfter  time.Duration
+	now         func() time.Time
+
+	lock     sync.Mutex
+	inFlight *list.List // 进行中请求的开始时间，按开始时间排序，最前面的是最早的请求
+	ewma     float64    // 纳秒
+	last     time.Time
+}
+
+func newLoadShedder(opts LoadShedOptions) *loadShedder {
+	if opts.TargetLatency <= 0 {
+		panic("httpkit/server: LoadShedOptions.TargetLatency必须大于0")
+	}
+
+	if opts.MinInFlight <= 0 {
+		opts.MinInFlight = 1
+	}
+
+	if opts.Decay <= 0 || opts.Decay > 1 {
+		opts.Decay = 0.1
+	}
+
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = time.Second
+	}
+
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = time.Second
+	}
+
+	return &loadShedder{
+		target:      opts.TargetLatency,
+		minInFlight: opts.MinInFlight,
+		decay:       opts.Decay,
+		halfLife:    opts.HalfLife,
+		retryAfter:  opts.RetryAfter,
+		now:         time.Now,
+		inFlight:    list.New(),
+	}
+}
+
+// admit 允许时记录请求的开始时间，返回值需传给done
+func (s *loadShedder) admit() (*list.Element, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	if s.inFlight.Len() >= s.minInFlight && s.overloaded(now) {
+		return nil, false
+	}
+
+	return s.inFlight.PushBack(now), true
+}
+
+func (s *loadShedder) done(req *list.Element) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	latency := now.Sub(s.inFlight.Remove(req).(time.Time))
+
+	ewma := s.decayed(now)
+	s.last = now
+
+	if ewma == 0 {
+		s.ewma = float64(latency)
+		return
+	}
+	s.ewma = s.decay*float64(latency) + (1-s.decay)*ewma
+}
+
+// overloaded 滑动平均或最早的进行中请求的耗时超过目标耗时，调用方需持有锁
+func (s *loadShedder) overloaded(now time.Time) bool {
+	if time.Duration(s.decayed(now)) > s.target {
+		return true
+	}
+
+	oldest := s.inFlight.Front()
+	return oldest != nil && now.Sub(oldest.Value.(time.Time)) > s.target
+}
+
+// decayed 返回按距上次请求完成的时间衰减后的滑动平均，
+// 拒绝期间没有新样本，平均值随时间下降，降到目标耗时以下后重新放行，
+// 后端卡住时由进行中请求的耗时继续拒绝，调用方需持有锁
+func (s *loadShedder) decayed(now time.Time) float64 {
+	elapsed := now.Sub(s.last)
+	if s.ewma == 0 || elapsed <= 0 {
+		return s.ewma
+	}
+
+	return s.ewma * math.Exp2(-float64(elapsed)/float64(s.halfLife))
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type step struct {
+		key     string
+		advance time.Duration
+		wait    time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		opts  RateLimitOptions
+		steps []step
+	}{
+		{
+			name: "burst then refill",
+			opts: RateLimitOptions{Rate: 2, Burst: 2},
+			steps: []step{
+				{key: "a"},
+				{key: "a"},
+				{key: "a", wait: 500 * time.Millisecond},
+				{key: "a", advance: 250 * time.Millisecond, wait: 250 * time.Millisecond},
+				{key: "a", advance: 250 * time.Millisecond},
+			},
+		},
+		{
+			name: "keys are independent",
+			opts: RateLimitOptions{Rate: 1},
+			steps: []step{
+				{key: "a"},
+				{key: "a", wait: time.Second},
+				{key: "b"},
+			},
+		},
+		{
+			name: "idle buckets are swept",
+			opts: RateLimitOptions{Rate: 1, IdleTTL: time.Minute},
+			steps: []step{
+				{key: "a"},
+				{key: "b", advance: 2 * time.Minute},
+				{key: "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter(tt.opts)
+			now := time.Now()
+			limiter.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				if wait := limiter.take(s.key); wait != s.wait {
+					t.Fatalf("step %d: expected wait %v, got %v", i, s.wait, wait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := gin.New()
+	router.Use(RateLimit(RateLimitOptions{
+		Rate:  0.5,
+		Burst: 1,
+		KeyFunc: func(c *gin.Context) string {
+			return c.GetHeader("X-Api-Key")
+		},
+	}))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		key        string
+		httpCode   int
+		retryAfter string
+	}{
+		{name: "first", key: "k1", httpCode: http.StatusOK},
+		{name: "limited", key: "k1", httpCode: http.StatusTooManyRequests, retryAfter: "2"},
+		{name: "other key", key: "k2", httpCode: http.StatusOK},
+		{name: "no key", key: "", httpCode: http.StatusOK},
+		{name: "no key again", key: "", httpCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", tt.key)
+		w := serve(router, req)
+
+		if w.Code != tt.httpCode || w.Header().Get("Retry-After") != tt.retryAfter {
+			t.Fatalf("%s: expected %d %q, got %d %q", tt.name, tt.httpCode, tt.retryAfter, w.Code, w.Header().Get("Retry-After"))
+		}
+
+		if tt.httpCode == http.StatusTooManyRequests && w.Body.String() != `{"code":2004,"msg":"[请求过于频繁]: 请2秒后重试"}` {
+			t.Fatalf("unexpected envelope %s", w.Body.String())
+		}
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RateLimitOptions
+	}{
+		{name: "zero", opts: RateLimitOptions{}},
+		{name: "negative", opts: RateLimitOptions{Rate: -1, Burst: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(RateLimit(tt.opts))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i := 0; i < 3; i++ {
+				if w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil)); w.Code != http.StatusOK {
+					t.Fatalf("request %d: expected no limit, got %d", i, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitUnlimited(t *testing.T) {
+	for _, max := range []int{0, -1} {
+		t.Run(strconv.Itoa(max), func(t *testing.T) {
+			router := gin.New()
+			router.Use(ConcurrencyLimit(max))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			if w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil)); w.Code != http.StatusOK {
+				t.Fatalf("expected no limit, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{}, 2)
+
+	router := gin.New()
+	router.Use(ConcurrencyLimit(2))
+	router.GET("/", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(router, httptest.NewRequest(http.MethodGet, "/", nil)).Code
+		}()
+	}
+	<-entered
+	<-entered
+
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 503 when limit reached, got %d", w.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("admitted request failed, %d", code)
+		}
+	}
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/", nil)); w.Code != http.StatusOK {
+		t.Fatalf("expected capacity to be released, got %d", w.Code)
+	}
+}
+
+func TestLoadShed(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     []time.Duration
+		idle        time.Duration // 最后一个样本之后经过的时间
+		inFlight    int
+		inFlightAge time.Duration // 进行中请求已经过的时间
+		admit       bool
+	}{
+		{name: "no samples", inFlight: 3, admit: true},
+		{name: "below target", samples: []time.Duration{10 * time.Millisecond}, inFlight: 3, admit: true},
+		{name: "above target", samples: []time.Duration{200 * time.Millisecond}, inFlight: 3, admit: false},
+		{name: "above target but idle", samples: []time.Duration{200 * time.Millisecond}, inFlight: 0, admit: true},
+		{
+			name:     "recovered",
+			samples:  []time.Duration{200 * time.Millisecond, time.Millisecond, time.Millisecond, time.Millisecond},
+			inFlight: 3,
+			admit:    true,
+		},
+		{name: "decayed without samples", samples: []time.Duration{400 * time.Millisecond}, idle: 2500 * time.Millisecond, inFlight: 3, admit: true},
+		{name: "not decayed enough", samples: []time.Duration{400 * time.Millisecond}, idle: 1500 * time.Millisecond, inFlight: 3, admit: false},
+		{name: "in flight below target", inFlight: 3, inFlightAge: 50 * time.Millisecond, admit: true},
+		{name: "stalled in flight", inFlight: 3, inFlightAge: 200 * time.Millisecond, admit: false},
+		{
+			name:        "stalled after average decayed",
+			samples:     []time.Duration{10 * time.Millisecond},
+			idle:        5 * time.Second,
+			inFlight:    3,
+			inFlightAge: 5 * time.Second,
+			admit:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLoadShedder(LoadShedOptions{TargetLatency: 100 * time.Millisecond, Decay: 0.5, HalfLife: time.Second})
+			now := time.Now()
+			s.now = func() time.Time { return now }
+			for _, sample := range tt.samples {
+				req, ok := s.admit()
+				if !ok {
+					t.Fatalf("sample request should be admitted")
+				}
+				now = now.Add(sample)
+				s.done(req)
+			}
+			now = now.Add(tt.idle)
+			for i := 0; i < tt.inFlight; i++ {
+				s.inFlight.PushBack(now.Add(-tt.inFlightAge))
+			}
+
+			if _, admit := s.admit(); admit != tt.admit {
+				t.Fatalf("expected admit %v, latency %v", tt.admit, time.Duration(s.decayed(now)))
+			}
+		})
+	}
+}
+
+func TestLoadShedInvalidTarget(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for zero TargetLatency")
+		}
+	}()
+
+	LoadShed(LoadShedOptions{})
+}
+
+func TestLoadShedMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{}, 1)
+
+	router := gin.New()
+	router.Use(LoadShed(LoadShedOptions{TargetLatency: 10 * time.Millisecond, HalfLife: 50 * time.Millisecond, RetryAfter: 3 * time.Second}))
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/block", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/slow", nil)); w.Code != http.StatusOK {
+		t.Fatalf("first request should pass, got %d", w.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve(router, httptest.NewRequest(http.MethodGet, "/block", nil))
+		close(done)
+	}()
+	<-entered
+
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "3" {
+		t.Fatalf("expected shedding, got %d %v", w.Code, w.Header())
+	}
+
+	// 滑动平均已衰减，但阻塞的请求一直未完成，仍然拒绝
+	time.Sleep(150 * time.Millisecond)
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/fast", nil)); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected shedding while request is stalled, got %d", w.Code)
+	}
+
+	close(release)
+	<-done
+
+	if w := serve(router, httptest.NewRequest(http.MethodGet, "/fast", nil)); w.Code != http.StatusOK {
+		t.Fatalf("expected recovery after stalled request finished, got %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope 统一的响应格式，code为0表示成功，成功时result为零值也会输出，失败时没有result
+// 客户端可使用Envelope[T]解析响应
+type Envelope[T any] struct {
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	Result T      `json:"result"`
+}
+
+type errorEnvelope struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// OK 返回200及result
+func OK[T any](c *gin.Context, result T) {
+	JSON(c, http.StatusOK, result)
+}
+
+// JSON 返回httpCode及result，code为0
+func JSON[T any](c *gin.Context, httpCode int, result T) {
+	c.JSON(httpCode, Envelope[T]{
+		Code:   SUCCESS,
+		Msg:    "OK",
+		Result: result,
+	})
+}
+
+// Fail 返回错误并中止后续handler，msg前会加上错误码在DefaultCatalog中注册的前缀
+func Fail(c *gin.Context, httpCode, code int, msg string) {
+	c.AbortWithStatusJSON(httpCode, errorEnvelope{
+		Code: code,
+		Msg:  DefaultCatalog.Format(code, msg),
+	})
+}
+
+// FailErr 与Fail相同，使用err作为错误信息
+func FailErr(c *gin.Context, httpCode, code int, err error) {
+	Fail(c, httpCode, code, err.Error())
+}
+
+// SetStrResp 保留兼容
+//
+// Deprecated: 使用OK、JSON、Fail
+func SetStrResp(httpCode, code int, msg string, result interface{}, c *gin.Context) {
+	if code != SUCCESS {
+		Fail(c, httpCode, code, msg)
+		return
+	}
+
+	c.JSON(httpCode, Envelope[interface{}]{
+		Code:   code,
+		Msg:    DefaultCatalog.Format(code, msg),
+		Result: result,
+	})
+}
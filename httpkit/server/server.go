@@ -1,83 +1,247 @@
-package main
+package server
 
 import (
-	"fmt"
-	"io"
-	"log"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var server *http.Server
-var router *gin.Engine
+const (
+	DefaultAddr              = "0.0.0.0:8080"
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 90 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Options server的配置，零值字段使用默认值
+type Options struct {
+	Addr              string        // 监听地址，默认0.0.0.0:8080
+	ReadTimeout       time.Duration // 读取整个请求的超时时间，0不限制
+	ReadHeaderTimeout time.Duration // 读取请求头的超时时间，默认10s
+	WriteTimeout      time.Duration // 写响应的超时时间，0不限制
+	IdleTimeout       time.Duration // keep-alive连接的空闲时间，默认90s
+	MaxHeaderBytes    int
+
+	TLSConfig *tls.Config // 设置后使用https，证书可使用httpkit.NewTLSConfig加载
+	CertFile  string      // TLSConfig中未设置证书时使用
+	KeyFile   string
+
+	// 收到SIGTERM、SIGINT后先等待DrainDelay(此时Draining()返回true，负载均衡可摘除流量)，
+	// 再等待进行中的请求完成，最多等待ShutdownTimeout，超时后强制关闭连接
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration // 默认30s
 
-type TestData struct {
-	Name string `json:"name"`
+	Logger Logger // 默认的Recovery中间件记录panic使用，可为nil
+
+	Admin *AdminOptions // 设置后在单独的端口提供pprof、构建信息及日志级别修改
 }
 
-func main() {
-	router = gin.Default()
-	router.POST("/test", func(c *gin.Context) {
-		log.Println("req.headers", c.Request.Header)
+func (opts *Options) setDefaults() {
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
 
-		bytes, err := io.ReadAll(c.Request.Body)
-		if err != nil {
-			panic("sdfsdf" + err.Error())
-			SetStrResp(http.StatusBadRequest, HTTP_BODY_ERR, err.Error(), "", c)
-			return
+	if opts.ReadHeaderTimeout <= 0 {
+		opts.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+}
+
+// Server 基于gin的http server，支持优雅退出
+type Server struct {
+	opts   Options
+	router *gin.Engine
+	server *http.Server
+
+	admin  *http.Server
+	health health
+
+	addr      atomic.Value // 实际监听的地址
+	adminAddr atomic.Value
+	draining  atomic.Bool
+}
+
+func New(opts Options) *Server {
+	opts.setDefaults()
+
+	router := gin.New()
+	router.Use(RequestID(), Recovery(opts.Logger))
+
+	s := &Server{
+		opts:   opts,
+		router: router,
+	}
+
+	s.server = &http.Server{
+		Addr:              opts.Addr,
+		Handler:           router,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+		TLSConfig:         opts.TLSConfig,
+	}
+
+	if opts.Admin != nil {
+		s.admin = &http.Server{
+			Addr:              opts.Admin.Addr,
+			Handler:           AdminHandler(*opts.Admin),
+			ReadHeaderTimeout: opts.ReadHeaderTimeout,
 		}
+	}
+
+	s.mountHealth()
+
+	return s
+}
+
+// Router 返回gin的路由，用于注册handler和中间件
+func (s *Server) Router() *gin.Engine {
+	return s.router
+}
+
+// Addr 返回实际监听的地址，未启动时返回配置的地址
+func (s *Server) Addr() string {
+	if addr, ok := s.addr.Load().(string); ok {
+		return addr
+	}
 
-		log.Println("121221", string(bytes))
+	return s.opts.Addr
+}
 
-		SetStrResp(400, 0, "OK", "123", c)
-	})
+// AdminAddr 返回管理端口实际监听的地址，未启动时返回配置的地址
+func (s *Server) AdminAddr() string {
+	if addr, ok := s.adminAddr.Load().(string); ok {
+		return addr
+	}
 
-	server = &http.Server{
-		Addr:    fmt.Sprintf("0.0.0.0:12745"),
-		Handler: router,
+	if s.opts.Admin != nil {
+		return s.opts.Admin.Addr
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		fmt.Println("listenAndServe error ", err.Error())
-		os.Exit(-1)
+	return ""
+}
+
+// Draining 收到退出信号后返回true
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// RegisterOnShutdown 注册退出时调用的函数，如关闭websocket、长连接
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.server.RegisterOnShutdown(f)
+}
+
+// Run 启动server并阻塞，收到SIGTERM、SIGINT后优雅退出
+func (s *Server) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	return s.RunContext(ctx)
+}
+
+// RunContext 启动server并阻塞，ctx结束后优雅退出
+func (s *Server) RunContext(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
 	}
+
+	return s.Serve(ctx, ln)
 }
 
-const (
-	JSON_UNMARSHAL = 1000
-	HTTP_BODY_ERR  = 1001
+// Serve 在ln上提供服务，ctx结束后优雅退出
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.addr.Store(ln.Addr().String())
 
-	SERVER_ERR = 2000
-	RPC_ERR    = 2001
-)
+	if s.admin != nil {
+		adminLn, err := net.Listen("tcp", s.admin.Addr)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		s.adminAddr.Store(adminLn.Addr().String())
 
-var errCodeMsg = map[int]string{
-	JSON_UNMARSHAL: "[JSON 反序列化异常]: ",
-	HTTP_BODY_ERR:  "[HTTP BODY读取异常]: ",
+		go s.admin.Serve(adminLn)
+	}
 
-	SERVER_ERR: "[选择后端节点异常]: ",
-	RPC_ERR:    "[远端服务器调用出错]: ",
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		if s.admin != nil {
+			s.admin.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		return err
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
 }
 
-func SetStrResp(httpCode, code int, msg string, result interface{}, c *gin.Context) {
-	m := msg
+func (s *Server) serve(ln net.Listener) error {
+	tlsCfg := s.server.TLSConfig
+	if tlsCfg == nil && s.opts.CertFile == "" {
+		return s.server.Serve(ln)
+	}
+
+	return s.server.ServeTLS(ln, s.opts.CertFile, s.opts.KeyFile)
+}
+
+// Shutdown 等待DrainDelay后停止接收新连接，等待进行中的请求完成
+// 超过ShutdownTimeout或ctx结束后强制关闭连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+
+	if s.opts.DrainDelay > 0 {
+		timer := time.NewTimer(s.opts.DrainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
 
-	if v, ok := errCodeMsg[code]; ok {
-		m = fmt.Sprintf("%s%s", v, msg)
+	ctx, cancel := context.WithTimeout(ctx, s.opts.ShutdownTimeout)
+	defer cancel()
+
+	// 管理端口在业务请求处理完成后再关闭，便于排查退出过程
+	if s.admin != nil {
+		defer s.admin.Close()
 	}
 
-	if code == 0 {
-		c.JSON(httpCode, gin.H{
-			"code":   code,
-			"msg":    m,
-			"result": result,
-		})
-	} else {
-		c.JSON(httpCode, gin.H{
-			"code": code,
-			"msg":  m,
-		})
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+		return err
 	}
+
+	return nil
 }
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	srv := New(Options{Addr: "127.0.0.1:0", DrainDelay: 50 * time.Millisecond, ShutdownTimeout: 2 * time.Second})
+
+	started := make(chan struct{})
+	srv.Router().GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		OK(c, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed, %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ctx, ln)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	respCh := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			respCh <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		respCh <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// 退出期间进行中的请求正常完成
+	r := <-respCh
+	if r.err != nil || r.body != `{"code":0,"msg":"OK","result":"done"}` {
+		t.Fatalf("in-flight request should be drained, %q %v", r.body, r.err)
+	}
+
+	if !srv.Draining() {
+		t.Fatalf("server should be draining")
+	}
+
+	if err := <-serveErr; err != nil {
+		t.Fatalf("serve returned error, %v", err)
+	}
+
+	if _, err := http.Get("http://" + srv.Addr() + "/slow"); err == nil {
+		t.Fatalf("server should be closed")
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	catalog := NewCatalog()
+	if err := catalog.Register(3000, "[业务异常]: "); err != nil {
+		t.Fatalf("register failed, %v", err)
+	}
+	if err := catalog.Register(3000, "dup"); err == nil {
+		t.Fatalf("duplicate code should be rejected")
+	}
+	if err := catalog.Register(SUCCESS, "ok"); err == nil {
+		t.Fatalf("success code should be reserved")
+	}
+	if msg := catalog.Format(3000, "x"); msg != "[业务异常]: x" {
+		t.Fatalf("unexpected format %q", msg)
+	}
+
+	tests := []struct {
+		name     string
+		handler  gin.HandlerFunc
+		httpCode int
+		body     string
+	}{
+		{
+			name:     "ok",
+			handler:  func(c *gin.Context) { OK(c, map[string]int{"n": 1}) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":{"n":1}}`,
+		},
+		{
+			name:     "created",
+			handler:  func(c *gin.Context) { JSON(c, http.StatusCreated, []string{"a"}) },
+			httpCode: http.StatusCreated,
+			body:     `{"code":0,"msg":"OK","result":["a"]}`,
+		},
+		{
+			name:     "zero result",
+			handler:  func(c *gin.Context) { OK(c, 0) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":0}`,
+		},
+		{
+			name:     "nil result",
+			handler:  func(c *gin.Context) { OK[[]string](c, nil) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":null}`,
+		},
+		{
+			name:     "legacy empty result",
+			handler:  func(c *gin.Context) { SetStrResp(http.StatusOK, SUCCESS, "OK", "", c) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":""}`,
+		},
+		{
+			name:     "fail with catalog prefix",
+			handler:  func(c *gin.Context) { FailErr(c, http.StatusBadRequest, HTTP_BODY_ERR, errors.New("eof")) },
+			httpCode: http.StatusBadRequest,
+			body:     `{"code":1001,"msg":"[HTTP BODY读取异常]: eof"}`,
+		},
+		{
+			name:     "fail unregistered code",
+			handler:  func(c *gin.Context) { Fail(c, http.StatusConflict, 4999, "conflict") },
+			httpCode: http.StatusConflict,
+			body:     `{"code":4999,"msg":"conflict"}`,
+		},
+		{
+			name:     "legacy",
+			handler:  func(c *gin.Context) { SetStrResp(http.StatusOK, SUCCESS, "OK", "123", c) },
+			httpCode: http.StatusOK,
+			body:     `{"code":0,"msg":"OK","result":"123"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			tt.handler(c)
+
+			if w.Code != tt.httpCode || w.Body.String() != tt.body {
+				t.Fatalf("expected %d %s, got %d %s", tt.httpCode, tt.body, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	var env Envelope[map[string]int]
+	if err := json.Unmarshal([]byte(tests[0].body), &env); err != nil || env.Result["n"] != 1 {
+		t.Fatalf("envelope decode failed, %v %+v", err, env)
+	}
+}
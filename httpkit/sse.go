@@ -0,0 +1,272 @@
+package httpkit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEventSourceRetry 未设置retryInterval且服务端未指定retry时的重连间隔
+const DefaultEventSourceRetry = 3 * time.Second
+
+// maxEventLineSize SSE单行的最大字节数
+const maxEventLineSize = 1 << 20
+
+// Event Server-Sent Events事件
+type Event struct {
+	ID    string // 最近一次收到的事件id，即重连时的Last-Event-ID
+	Event string // 事件类型，服务端未指定时为message
+	Data  []byte // 多行data以换行连接
+	Retry time.Duration
+}
+
+// EventSource SSE客户端，连接断开后携带Last-Event-ID自动重连，不可并发使用
+type EventSource struct {
+	engine *engine
+	u      url.URL
+	opts   requestOptions
+
+	lastEventID string
+	retry       time.Duration
+}
+
+func newEventSource(e *engine, u url.URL, opts *requestOptions) *EventSource {
+	es := &EventSource{
+		engine: e,
+		u:      u,
+		opts:   opts.clone(),
+		retry:  opts.retryInterval,
+	}
+
+	if es.retry <= 0 {
+		es.retry = DefaultEventSourceRetry
+	}
+
+	es.opts.rawBody = nil
+	es.opts.headers.Set("Accept", "text/event-stream")
+	es.opts.headers.Set("Cache-Control", "no-cache")
+
+	return es
+}
+
+// LastEventID 最近一次收到的事件id
+func (es *EventSource) LastEventID() string {
+	return es.lastEventID
+}
+
+// Subscribe 阻塞读取事件并回调handler，连接断开或重连失败后等待retry时间重连，
+// 每次连接按客户端的重试设置重试，ctx取消、handler返回错误时返回对应的错误，
+// 服务端返回204时返回nil，返回4xx等非5xx状态码或Content-Type错误时不再重连并返回错误
+func (es *EventSource) Subscribe(ctx context.Context, handler func(Event) error) error {
+	for {
+		// 服务端以空的id字段重置后不再携带Last-Event-ID
+		if es.lastEventID != "" {
+			es.opts.headers.Set("Last-Event-ID", es.lastEventID)
+		} else {
+			es.opts.headers.Del("Last-Event-ID")
+		}
+
+		resp, err := es.engine.openStream(ctx, http.MethodGet, es.u, &es.opts)
+		if err == nil {
+			err = es.handleStream(resp, handler)
+		}
+
+		var herr *handlerError
+		if errors.As(err, &herr) {
+			return herr.err
+		}
+
+		var serr *streamStatusError
+		if errors.As(err, &serr) {
+			if serr.statusCode == http.StatusNoContent {
+				return nil
+			}
+			if serr.statusCode < 500 {
+				return serr
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := sleepContext(ctx, es.retry); err != nil {
+			return err
+		}
+	}
+}
+
+// handleStream 读取一次连接的事件，连接正常断开时返回nil
+func (es *EventSource) handleStream(resp *http.Response, handler func(Event) error) error {
+	defer resp.Body.Close()
+
+	if err := checkEventStream(resp); err != nil {
+		return err
+	}
+
+	return es.consume(resp.Body, handler)
+}
+
+// Events 在后台订阅事件并通过channel返回，订阅结束时events关闭，errs返回结束原因
+func (es *EventSource) Events(ctx context.Context, size int) (<-chan Event, <-chan error) {
+	events := make(chan Event, size)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+
+		errs <- es.Subscribe(ctx, func(ev Event) error {
+			select {
+			case events <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(errs)
+	}()
+
+	return events, errs
+}
+
+// streamStatusError 非200的状态码或Content-Type错误，Content-Type错误时statusCode为200
+type streamStatusError struct {
+	statusCode int
+	msg        string
+}
+
+func (e *streamStatusError) Error() string {
+	return e.msg
+}
+
+func checkEventStream(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return &streamStatusError{statusCode: resp.StatusCode, msg: fmt.Sprintf("sse请求失败, status: %s", resp.Status)}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/event-stream" {
+		return &streamStatusError{statusCode: resp.StatusCode, msg: fmt.Sprintf("sse响应的Content-Type错误: %s", resp.Header.Get("Content-Type"))}
+	}
+
+	return nil
+}
+
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// consume 按行解析事件流，直到连接断开或handler返回错误
+func (es *EventSource) consume(body io.Reader, handler func(Event) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 4096), maxEventLineSize)
+	scanner.Split(scanEventLines)
+
+	var (
+		eventType string
+		data      bytes.Buffer
+		hasData   bool
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// 空行表示一个事件结束
+		if line == "" {
+			if hasData {
+				ev := Event{
+					ID:    es.lastEventID,
+					Event: eventType,
+					Data:  bytes.TrimSuffix(data.Bytes(), []byte("\n")),
+					Retry: es.retry,
+				}
+				if ev.Event == "" {
+					ev.Event = "message"
+				}
+				ev.Data = append([]byte(nil), ev.Data...)
+
+				if err := handler(ev); err != nil {
+					return &handlerError{err: err}
+				}
+			}
+
+			eventType = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+
+		// 注释行
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				es.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				es.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// scanEventLines 按\r\n、\n、\r分行
+func scanEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// \r后需要确认是否紧跟\n
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,333 @@
+package httpkit
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventSourceParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+		lastID string
+	}{
+		{
+			name:   "default message",
+			stream: "data: hello\n\n",
+			want:   []Event{{Event: "message", Data: []byte("hello")}},
+		},
+		{
+			name:   "multi line data and crlf",
+			stream: "event: update\r\nid: 7\r\ndata: a\r\ndata:b\r\n\r\n",
+			want:   []Event{{ID: "7", Event: "update", Data: []byte("a\nb")}},
+			lastID: "7",
+		},
+		{
+			name:   "comment and id only",
+			stream: ": ping\n\nid: 3\n\ndata: x\r\r",
+			want:   []Event{{ID: "3", Event: "message", Data: []byte("x")}},
+			lastID: "3",
+		},
+		{
+			name:   "incomplete event discarded",
+			stream: "data: first\n\ndata: partial",
+			want:   []Event{{Event: "message", Data: []byte("first")}},
+		},
+		{
+			name:   "retry field",
+			stream: "retry: 1500\ndata: r\n\nretry: bad\n\n",
+			want:   []Event{{Event: "message", Data: []byte("r"), Retry: 1500 * time.Millisecond}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := &EventSource{}
+
+			var got []Event
+			err := es.consume(strings.NewReader(tt.stream), func(ev Event) error {
+				got = append(got, ev)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("consume failed, %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d events, got %+v", len(tt.want), got)
+			}
+
+			for i := range got {
+				if got[i].ID != tt.want[i].ID || got[i].Event != tt.want[i].Event ||
+					string(got[i].Data) != string(tt.want[i].Data) || got[i].Retry != tt.want[i].Retry {
+					t.Fatalf("event %d: expected %+v, got %+v", i, tt.want[i], got[i])
+				}
+			}
+
+			if es.LastEventID() != tt.lastID {
+				t.Fatalf("expected last event id %q, got %q", tt.lastID, es.LastEventID())
+			}
+		})
+	}
+}
+
+func TestEventSourceReconnect(t *testing.T) {
+	var conns atomic.Int32
+	lastIDs := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := conns.Add(1)
+		lastIDs <- r.Header.Get("Last-Event-ID")
+
+		if n == 1 {
+			// 首次连接先返回可重试的状态码
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch n {
+		case 2:
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: one\n\nid: 2\ndata: two\n\n")
+		case 3:
+			fmt.Fprint(w, "id: 3\nevent: done\ndata: three\n\n")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(time.Second, 1, time.Millisecond, time.Second, nil, http.StatusTooManyRequests)
+	es, err := client.EventSource(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("create event source failed, %v", err)
+	}
+
+	events, errs := es.Events(context.Background(), 0)
+
+	var got []string
+	for ev := range events {
+		got = append(got, ev.ID+":"+ev.Event+":"+string(ev.Data))
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("subscribe failed, %v", err)
+	}
+
+	if strings.Join(got, ",") != "1:message:one,2:message:two,3:done:three" {
+		t.Fatalf("unexpected events %v", got)
+	}
+
+	close(lastIDs)
+	var ids []string
+	for id := range lastIDs {
+		ids = append(ids, id)
+	}
+	if strings.Join(ids, ",") != ",,2,3" {
+		t.Fatalf("unexpected Last-Event-ID headers %q", ids)
+	}
+}
+
+func TestEventSourceReconnectFailure(t *testing.T) {
+	var conns atomic.Int32
+	lastIDs := make(chan []string, 8)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := conns.Add(1)
+		lastIDs <- r.Header.Values("Last-Event-ID")
+
+		switch n {
+		case 1:
+			// 直接断开连接，openStream返回错误
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case 5:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch n {
+		case 3:
+			fmt.Fprint(w, "id: 5\ndata: a\n\n")
+		case 4:
+			// 空的id字段重置Last-Event-ID
+			fmt.Fprint(w, "id\ndata: b\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(time.Second, 0, time.Millisecond, time.Second, nil)
+	es, err := client.EventSource(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("create event source failed, %v", err)
+	}
+
+	var got []string
+	err = es.Subscribe(context.Background(), func(ev Event) error {
+		got = append(got, ev.ID+":"+string(ev.Data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subscribe should end with 204, got %v", err)
+	}
+
+	if strings.Join(got, ",") != "5:a,:b" {
+		t.Fatalf("unexpected events %v", got)
+	}
+
+	close(lastIDs)
+	var headers []string
+	for ids := range lastIDs {
+		headers = append(headers, fmt.Sprint(ids))
+	}
+	if strings.Join(headers, ",") != "[],[],[],[5],[]" {
+		t.Fatalf("unexpected Last-Event-ID headers %v", headers)
+	}
+}
+
+func TestEventSourceStatusError(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conns.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(time.Second, 0, time.Millisecond, time.Second, nil)
+	es, err := client.EventSource(srv.URL + "/events")
+	if err != nil {
+		t.Fatalf("create event source failed, %v", err)
+	}
+
+	err = es.Subscribe(context.Background(), func(ev Event) error { return nil })
+	if err == nil || conns.Load() != 1 {
+		t.Fatalf("4xx should stop the subscription, got %v after %d connections", err, conns.Load())
+	}
+}
+
+func TestEventSourceHandlerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.(http.Flusher).Flush()
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewAdvanceHttpClient("http", strings.TrimPrefix(srv.URL, "http://"), time.Second, nil)
+	es, err := client.EventSource("/events", NewAdvanceSettings(time.Second, 0, 0))
+	if err != nil {
+		t.Fatalf("create event source failed, %v", err)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	err = es.Subscribe(context.Background(), func(ev Event) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || count != 3 {
+		t.Fatalf("expected handler error after 3 events, got %v %d", err, count)
+	}
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		fmt.Fprint(gw, "{\"n\":1}\n\n{\"n\":2}\r\n{\"n\":3}")
+		gw.Close()
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(time.Second, 0, 0, time.Second, nil)
+	resp, err := client.Stream(context.Background(), http.MethodGet, srv.URL+"/items")
+	if err != nil {
+		t.Fatalf("stream failed, %v", err)
+	}
+	defer resp.Close()
+
+	if resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("Content-Encoding should be removed after decoding")
+	}
+
+	dec := resp.NDJSON()
+	var sum int
+	for {
+		var item struct{ N int }
+		err := dec.Decode(&item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode failed, %v", err)
+		}
+		sum += item.N
+	}
+
+	if sum != 6 {
+		t.Fatalf("expected sum 6, got %d", sum)
+	}
+
+	if err := NewNDJSONDecoder(strings.NewReader("{}\nnot json\n")).Decode(&struct{}{}); err != nil {
+		t.Fatalf("first line should decode, %v", err)
+	}
+
+	dec = NewNDJSONDecoder(strings.NewReader("{}\nnot json\n"))
+	dec.Decode(&struct{}{})
+	if err := dec.Decode(&struct{}{}); err == nil || !strings.Contains(err.Error(), "第2行") {
+		t.Fatalf("expected line number in error, got %v", err)
+	}
+}
+
+func TestStreamHeaderTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		// 响应头返回后body慢于rwTimeout也不会超时
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("late"))
+	}))
+	defer srv.Close()
+
+	client := NewHttpClient(50*time.Millisecond, 0, 0, time.Second, nil)
+	if _, err := client.Stream(context.Background(), http.MethodGet, srv.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected header timeout, got %v", err)
+	}
+
+	resp, err := client.Stream(context.Background(), http.MethodGet, srv.URL+"/fast")
+	if err != nil {
+		t.Fatalf("stream failed, %v", err)
+	}
+	defer resp.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "late" {
+		t.Fatalf("unexpected body %q %v", body, err)
+	}
+}
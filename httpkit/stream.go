@@ -0,0 +1,178 @@
+package httpkit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// StreamResponse 流式响应，body未读取，使用完毕后需调用Close
+type StreamResponse struct {
+	Header     http.Header
+	StatusCode int
+	Status     string
+	Body       io.ReadCloser
+}
+
+// NDJSON 按换行分隔的JSON解码body
+func (resp *StreamResponse) NDJSON() *NDJSONDecoder {
+	return NewNDJSONDecoder(resp.Body)
+}
+
+func (resp *StreamResponse) Close() error {
+	return resp.Body.Close()
+}
+
+// NDJSONDecoder 换行分隔的JSON(application/x-ndjson)解码器
+type NDJSONDecoder struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func NewNDJSONDecoder(r io.Reader) *NDJSONDecoder {
+	return &NDJSONDecoder{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Decode 读取下一行JSON并解码到v，空行会被跳过，流结束时返回io.EOF
+func (d *NDJSONDecoder) Decode(v any) error {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return err
+		}
+		d.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		if jerr := json.Unmarshal(line, v); jerr != nil {
+			return fmt.Errorf("ndjson第%d行解析失败: %w", d.line, jerr)
+		}
+
+		return nil
+	}
+}
+
+// streamBody 关闭body时取消请求的context
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (e *engine) stream(ctx context.Context, method string, u url.URL, opts *requestOptions) (*StreamResponse, error) {
+	resp, err := e.openStream(ctx, method, u, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamResponse{
+		Header:     resp.Header,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       resp.Body,
+	}, nil
+}
+
+// openStream 发起流式请求，返回body未读取的响应，调用方负责关闭resp.Body
+// rwTimeout仅限制等待响应头的时间，连接失败或命中重试状态码时按retry、retryInterval重试
+func (e *engine) openStream(ctx context.Context, method string, u url.URL, opts *requestOptions) (*http.Response, error) {
+	attempts := 1
+	if opts.retry > 0 {
+		attempts += opts.retry
+	}
+
+	for i := 0; ; i++ {
+		resp, err := e.openStreamOnce(ctx, method, u, opts)
+		last := i == attempts-1 || ctx.Err() != nil
+		if err != nil {
+			if last {
+				return nil, err
+			}
+		} else {
+			if last || !opts.retryCheck(resp.StatusCode) {
+				return resp, nil
+			}
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, opts.retryInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (e *engine) openStreamOnce(ctx context.Context, method string, u url.URL, opts *requestOptions) (*http.Response, error) {
+	resp, err := e.openStreamAttempt(ctx, method, u, opts)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	refresher, ok := opts.auth.(Refresher)
+	if !ok {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	if err := refresher.Refresh(ctx, resp.Request); err != nil {
+		return nil, err
+	}
+
+	return e.openStreamAttempt(ctx, method, u, opts)
+}
+
+func (e *engine) openStreamAttempt(ctx context.Context, method string, u url.URL, opts *requestOptions) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	req, err := e.newRequest(ctx, method, u, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var timer *time.Timer
+	if opts.rwTimeout > 0 {
+		timer = time.AfterFunc(opts.rwTimeout, cancel)
+	}
+
+	resp, err := e.client.Do(req)
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("等待响应头超时: %w", context.DeadlineExceeded)
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Header.Del(cacheStatusHeader)
+
+	body, err := opts.compress.decodeBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &streamBody{ReadCloser: body, cancel: cancel}
+	return resp, nil
+}
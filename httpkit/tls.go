@@ -0,0 +1,309 @@
+package httpkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSOptions 构建客户端tls.Config的参数
+type TLSOptions struct {
+	CertFile   string   // 客户端证书PEM文件，双向认证时使用
+	KeyFile    string   // 客户端私钥PEM文件
+	CAPaths    []string // CA证书PEM文件或目录，目录下的*.pem、*.crt文件都会被加载
+	RootCAOnly bool     // 只信任CAPaths中的CA，不使用系统CA
+	SPKIPins   []string // 证书公钥的sha256 base64值，证书链中任一证书匹配即通过
+	ServerName string
+
+	// 检查证书文件变化的最小间隔，文件变化后下次握手时自动重新加载，<=0不重新加载
+	ReloadInterval time.Duration
+}
+
+var (
+	ErrSPKIPinMismatch = errors.New("httpkit: 服务端证书公钥与SPKI pin不匹配")
+	ErrTLSServerName   = errors.New("httpkit: 无法确定校验证书的主机名，请设置TLSOptions.ServerName")
+)
+
+// SPKIPin 计算证书公钥的pin值，用于TLSOptions.SPKIPins
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// NewTLSConfig 根据PEM文件构建客户端TLS配置，支持双向认证、证书热加载、SPKI pin校验
+// 为了支持CA热加载，证书校验在VerifyConnection中完成，因此InsecureSkipVerify为true，
+// 主机名按ServerName、SNI校验，访问IP地址时由本包的transport传入拨号的IP，
+// 在其他transport中或经代理访问IP地址时需设置ServerName，否则握手失败
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("CertFile和KeyFile需同时设置")
+	}
+
+	if opts.RootCAOnly && len(opts.CAPaths) == 0 {
+		return nil, fmt.Errorf("RootCAOnly模式需要设置CAPaths")
+	}
+
+	loader := &tlsLoader{opts: opts}
+	if err := loader.load(); err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: true,
+		VerifyConnection:   loader.verifyConnection,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if opts.CertFile != "" {
+		cfg.GetClientCertificate = loader.getClientCertificate
+	}
+
+	return cfg, nil
+}
+
+type tlsLoader struct {
+	opts TLSOptions
+
+	lock      sync.RWMutex
+	cert      *tls.Certificate
+	roots     *x509.CertPool
+	snapshot  string
+	lastCheck time.Time
+}
+
+func (loader *tlsLoader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	loader.maybeReload()
+
+	loader.lock.RLock()
+	defer loader.lock.RUnlock()
+
+	return loader.cert, nil
+}
+
+func (loader *tlsLoader) verifyConnection(cs tls.ConnectionState) error {
+	loader.maybeReload()
+
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("httpkit: 服务端未提供证书")
+	}
+
+	loader.lock.RLock()
+	roots := loader.roots
+	loader.lock.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// 与crypto/tls相同，按ServerName或拨号的host(包括IP地址)校验证书，无法确定时握手失败
+	name := loader.opts.ServerName
+	if name == "" {
+		name = cs.ServerName
+	}
+	if name == "" {
+		return ErrTLSServerName
+	}
+
+	chains, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(loader.opts.SPKIPins) == 0 {
+		return nil
+	}
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKIPin(cert)
+			for _, want := range loader.opts.SPKIPins {
+				if pin == want {
+					return nil
+				}
+			}
+		}
+	}
+
+	return ErrSPKIPinMismatch
+}
+
+// dialTLSContext 本包transport的TLS拨号，握手时将拨号的host传给VerifyConnection，
+// 访问IP地址时SNI为空，否则VerifyConnection无法得知要校验的主机名
+func dialTLSContext(dialer *net.Dialer, base *tls.Config, handshakeTimeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := base.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		if verify := cfg.VerifyConnection; verify != nil {
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				if cs.ServerName == "" {
+					cs.ServerName = cfg.ServerName
+				}
+				return verify(cs)
+			}
+		}
+
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		if handshakeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, handshakeTimeout)
+			defer cancel()
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return tlsConn, nil
+	}
+}
+
+// maybeReload 距离上次检查超过ReloadInterval且文件有变化时重新加载，加载失败时继续使用旧证书
+func (loader *tlsLoader) maybeReload() {
+	if loader.opts.ReloadInterval <= 0 {
+		return
+	}
+
+	loader.lock.RLock()
+	due := time.Since(loader.lastCheck) >= loader.opts.ReloadInterval
+	loader.lock.RUnlock()
+	if !due {
+		return
+	}
+
+	loader.load()
+}
+
+func (loader *tlsLoader) load() error {
+	loader.lock.Lock()
+	defer loader.lock.Unlock()
+
+	loader.lastCheck = time.Now()
+
+	caFiles, err := expandCAPaths(loader.opts.CAPaths)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := fileSnapshot(append([]string{loader.opts.CertFile, loader.opts.KeyFile}, caFiles...))
+	if err != nil {
+		return err
+	}
+
+	if snapshot == loader.snapshot {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if loader.opts.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(loader.opts.CertFile, loader.opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var roots *x509.CertPool
+	if len(caFiles) > 0 {
+		if loader.opts.RootCAOnly {
+			roots = x509.NewCertPool()
+		} else if roots, err = x509.SystemCertPool(); err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		for _, file := range caFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			if !roots.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("CA文件[%s]中没有有效的PEM证书", file)
+			}
+		}
+	}
+
+	loader.cert = cert
+	loader.roots = roots
+	loader.snapshot = snapshot
+
+	return nil
+}
+
+func expandCAPaths(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if !entry.IsDir() && (ext == ".pem" || ext == ".crt") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileSnapshot 以文件的修改时间和大小判断文件是否变化
+func fileSnapshot(files []string) (string, error) {
+	sb := strings.Builder{}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&sb, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,237 @@
+package httpkit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key failed, %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate failed, %v", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func writePEM(t *testing.T, path string, certs ...*x509.Certificate) {
+	data := []byte{}
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write pem failed, %v", err)
+	}
+}
+
+func writeKeyPEM(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	der, _ := x509.MarshalECPrivateKey(key)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("write key failed, %v", err)
+	}
+}
+
+func TestNewTLSConfigMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "test ca", nil)
+	client := newTestCert(t, "test client", ca)
+	writePEM(t, filepath.Join(dir, "client.pem"), client.cert)
+	writeKeyPEM(t, filepath.Join(dir, "client.key"), client.key)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caDir := filepath.Join(dir, "ca")
+	os.Mkdir(caDir, 0755)
+	writePEM(t, filepath.Join(caDir, "server.crt"), srv.Certificate())
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+		ok   bool
+	}{
+		{"ca dir", TLSOptions{CAPaths: []string{caDir}, RootCAOnly: true}, true},
+		{"pin match", TLSOptions{CAPaths: []string{caDir}, RootCAOnly: true, SPKIPins: []string{SPKIPin(srv.Certificate())}}, true},
+		{"pin mismatch", TLSOptions{CAPaths: []string{caDir}, RootCAOnly: true, SPKIPins: []string{SPKIPin(ca.cert)}}, false},
+		{"untrusted server", TLSOptions{CAPaths: []string{filepath.Join(dir, "client.pem")}, RootCAOnly: true}, false},
+	}
+
+	for _, tt := range tests {
+		tt.opts.CertFile = filepath.Join(dir, "client.pem")
+		tt.opts.KeyFile = filepath.Join(dir, "client.key")
+
+		cfg, err := NewTLSConfig(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: new tls config failed, %v", tt.name, err)
+		}
+
+		resp, err := NewHttpClient(time.Second, 0, 0, time.Second, cfg).Get(srv.URL)
+		if !tt.ok {
+			if err == nil {
+				t.Fatalf("%s: expected tls error", tt.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: get failed, %v", tt.name, err)
+		}
+
+		if string(resp.Body) != "test client" {
+			t.Fatalf("%s: server should see client cert, got %q", tt.name, resp.Body)
+		}
+	}
+}
+
+func TestNewTLSConfigReload(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, newTestCert(t, "other ca", nil).cert)
+
+	cfg, err := NewTLSConfig(TLSOptions{
+		CAPaths:        []string{caFile},
+		RootCAOnly:     true,
+		ReloadInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("new tls config failed, %v", err)
+	}
+
+	client := NewAdvanceHttpClient("https", srv.Listener.Addr().String(), time.Second, cfg)
+	if _, err := client.Get("/", NewAdvanceSettings(time.Second, 0, 0)); err == nil {
+		t.Fatalf("expected tls error before ca rotation")
+	}
+
+	writePEM(t, caFile, srv.Certificate())
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(caFile, future, future)
+	time.Sleep(5 * time.Millisecond)
+
+	resp, err := client.Get("/", NewAdvanceSettings(time.Second, 0, 0))
+	if err != nil {
+		t.Fatalf("get after ca rotation failed, %v", err)
+	}
+
+	if string(resp.Body) != "ok" {
+		t.Fatalf("unexpected body %q", resp.Body)
+	}
+}
+
+func TestNewTLSConfigHostname(t *testing.T) {
+	ca := newTestCert(t, "test ca", nil)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "other.example"},
+		DNSNames:     []string{"other.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate failed, %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, ca.cert)
+
+	tests := []struct {
+		name       string
+		serverName string
+		stdlib     bool   // 使用标准库transport，拨号的IP无法传给VerifyConnection
+		err        string // 为空时期望请求成功
+	}{
+		{name: "ip does not match cert", err: "127.0.0.1"},
+		{name: "server name matches cert", serverName: "other.example"},
+		{name: "server name does not match cert", serverName: "example.com", err: "example.com"},
+		{name: "stdlib transport without server name", stdlib: true, err: ErrTLSServerName.Error()},
+		{name: "stdlib transport with server name", serverName: "other.example", stdlib: true},
+	}
+
+	for _, tt := range tests {
+		cfg, err := NewTLSConfig(TLSOptions{CAPaths: []string{caFile}, RootCAOnly: true, ServerName: tt.serverName})
+		if err != nil {
+			t.Fatalf("%s: new tls config failed, %v", tt.name, err)
+		}
+
+		if tt.stdlib {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+			var resp *http.Response
+			if resp, err = client.Get(srv.URL); err == nil {
+				resp.Body.Close()
+			}
+		} else {
+			_, err = NewHttpClient(time.Second, 0, 0, time.Second, cfg).Get(srv.URL)
+		}
+
+		if tt.err == "" && err != nil {
+			t.Fatalf("%s: get failed, %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package tclog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTimeFormat 文本格式默认的时间格式，精确到毫秒
+	DefaultTimeFormat = "2006/01/02 15:04:05.000"
+
+	// TimeFormatUnix、TimeFormatUnixMilli 时间输出为时间戳，JSON中为数字
+	TimeFormatUnix      = "unix"
+	TimeFormatUnixMilli = "unixms"
+)
+
+// Caller 日志的调用位置，EnableFuncCallDepth(true)时记录
+type Caller struct {
+	Defined  bool
+	Function string
+	File     string
+	Line     int
+}
+
+// Entry 一条日志，Encoder将其编码为一行
+type Entry struct {
+	Time    time.Time
+	Level   int
+	Host    string
+	Message string
+	Caller  Caller
+	Stack   string // EnableStacktrace设置的级别及以上的日志记录调用栈
+	Fields  []Field
+}
+
+// Encoder 日志的编码格式，将entry追加到buf并以换行结尾，需并发安全
+type Encoder interface {
+	Encode(buf []byte, entry *Entry) []byte
+}
+
+// EncoderConfig 编码器的配置
+type EncoderConfig struct {
+	TimeFormat string         // time.Format的格式或TimeFormatUnix、TimeFormatUnixMilli，默认DefaultTimeFormat
+	Location   *time.Location // 时间输出使用的时区，默认北京时间
+}
+
+func (cfg EncoderConfig) withDefaults() EncoderConfig {
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = DefaultTimeFormat
+	}
+
+	if cfg.Location == nil {
+		cfg.Location = cstLocal
+	}
+
+	return cfg
+}
+
+func (cfg EncoderConfig) appendTime(buf []byte, when time.Time) []byte {
+	when = when.In(cfg.Location)
+
+	switch cfg.TimeFormat {
+	case TimeFormatUnix:
+		return strconv.AppendInt(buf, when.Unix(), 10)
+	case TimeFormatUnixMilli:
+		return strconv.AppendInt(buf, when.UnixMilli(), 10)
+	case DefaultTimeFormat:
+		h, _ := formatTimeHeader(when)
+		return append(buf, h...)
+	default:
+		return when.AppendFormat(buf, cfg.TimeFormat)
+	}
+}
+
+// textEncoder 原有的文本格式: 时间 主机名 级别 消息 key=value...
+type textEncoder struct {
+	cfg EncoderConfig
+}
+
+// NewTextEncoder 默认的文本格式，调用栈输出在日志之后的行
+func NewTextEncoder(cfg EncoderConfig) Encoder {
+	return &textEncoder{cfg: cfg.withDefaults()}
+}
+
+func (enc *textEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = enc.cfg.appendTime(buf, e.Time)
+	buf = append(buf, ' ')
+	buf = append(buf, e.Host...)
+	buf = append(buf, ' ')
+	buf = append(buf, levelTextArray[e.Level]...)
+	buf = append(buf, ' ')
+
+	if e.Caller.Defined {
+		buf = fmt.Appendf(buf, "func:%s file:%s line:%d ", e.Caller.Function, e.Caller.File, e.Caller.Line)
+	}
+
+	buf = append(buf, e.Message...)
+	buf = appendFields(buf, e.Fields)
+	buf = append(buf, '\n')
+
+	if e.Stack != "" {
+		buf = append(buf, e.Stack...)
+		if !strings.HasSuffix(e.Stack, "\n") {
+			buf = append(buf, '\n')
+		}
+	}
+
+	return buf
+}
+
+// logfmtEncoder 每行都是key=value
+type logfmtEncoder struct {
+	cfg EncoderConfig
+}
+
+// NewLogfmtEncoder logfmt格式: time=... level=INFO host=... msg=... caller=file:line func=... key=value...
+func NewLogfmtEncoder(cfg EncoderConfig) Encoder {
+	return &logfmtEncoder{cfg: cfg.withDefaults()}
+}
+
+func (enc *logfmtEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = append(buf, "time="...)
+	start := len(buf)
+	buf = enc.cfg.appendTime(buf, e.Time)
+	if needQuote(string(buf[start:])) {
+		ts := string(buf[start:])
+		buf = strconv.AppendQuote(buf[:start], ts)
+	}
+
+	buf = append(buf, " level="...)
+	buf = append(buf, levelTextArray[e.Level]...)
+	buf = append(buf, " host="...)
+	buf = appendTextValue(buf, e.Host)
+	buf = append(buf, " msg="...)
+	buf = appendTextValue(buf, e.Message)
+
+	if e.Caller.Defined {
+		buf = append(buf, " caller="...)
+		buf = appendTextValue(buf, e.Caller.File+":"+strconv.Itoa(e.Caller.Line))
+		buf = append(buf, " func="...)
+		buf = appendTextValue(buf, e.Caller.Function)
+	}
+
+	buf = appendFields(buf, e.Fields)
+
+	if e.Stack != "" {
+		buf = append(buf, " stack="...)
+		buf = strconv.AppendQuote(buf, e.Stack)
+	}
+
+	return append(buf, '\n')
+}
+
+// jsonEncoder 每行一个JSON对象
+type jsonEncoder struct {
+	cfg EncoderConfig
+}
+
+// NewJSONEncoder JSON lines格式，固定的key为time、level、host、msg、caller、func、stack，
+// 结构化字段按原类型输出为同级的key
+func NewJSONEncoder(cfg EncoderConfig) Encoder {
+	return &jsonEncoder{cfg: cfg.withDefaults()}
+}
+
+func (enc *jsonEncoder) Encode(buf []byte, e *Entry) []byte {
+	buf = append(buf, `{"time":`...)
+	switch enc.cfg.TimeFormat {
+	case TimeFormatUnix, TimeFormatUnixMilli:
+		buf = enc.cfg.appendTime(buf, e.Time)
+	default:
+		buf = appendJSONString(buf, string(enc.cfg.appendTime(nil, e.Time)))
+	}
+
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONString(buf, levelTextArray[e.Level])
+	buf = append(buf, `,"host":`...)
+	buf = appendJSONString(buf, e.Host)
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, e.Message)
+
+	if e.Caller.Defined {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJSONString(buf, e.Caller.File+":"+strconv.Itoa(e.Caller.Line))
+		buf = append(buf, `,"func":`...)
+		buf = appendJSONString(buf, e.Caller.Function)
+	}
+
+	for _, f := range e.Fields {
+		if f.kind == skipKind {
+			continue
+		}
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = f.appendJSON(buf)
+	}
+
+	if e.Stack != "" {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJSONString(buf, e.Stack)
+	}
+
+	return append(buf, '}', '\n')
+}
+
+// appendJSON 按字段类型输出JSON值，Any使用encoding/json，失败时输出%v的字符串
+func (f Field) appendJSON(buf []byte) []byte {
+	switch f.kind {
+	case stringKind:
+		return appendJSONString(buf, f.s)
+	case intKind:
+		return strconv.AppendInt(buf, f.i, 10)
+	case uintKind:
+		return strconv.AppendUint(buf, uint64(f.i), 10)
+	case floatKind:
+		v := math.Float64frombits(uint64(f.i))
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return appendJSONString(buf, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return strconv.AppendFloat(buf, v, 'g', -1, 64)
+	case boolKind:
+		return strconv.AppendBool(buf, f.i == 1)
+	case durationKind:
+		return appendJSONString(buf, time.Duration(f.i).String())
+	case timeKind:
+		return appendJSONString(buf, f.v.(time.Time).Format(time.RFC3339Nano))
+	default:
+		data, err := json.Marshal(f.v)
+		if err != nil {
+			return appendJSONString(buf, fmt.Sprintf("%v", f.v))
+		}
+		return append(buf, data...)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString 追加带引号的JSON字符串，非法的UTF-8替换为U+FFFD
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, "\ufffd"...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+
+	return append(buf, '"')
+}
@@ -0,0 +1,160 @@
+package tclog
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncoders(t *testing.T) {
+	when := time.Date(2025, 3, 19, 2, 4, 5, 67e6, time.UTC)
+	entry := &Entry{
+		Time:    when,
+		Level:   LevelWarn,
+		Host:    "host-1",
+		Message: "slow query",
+		Fields: []Field{
+			String("sql", `select "a"`),
+			Int("rows", 3),
+			Float64("ratio", 0.25),
+			Bool("cached", true),
+			Duration("cost", 1500*time.Millisecond),
+			Err(nil),
+			Any("tags", []string{"a", "b"}),
+		},
+	}
+	withCaller := *entry
+	withCaller.Caller = Caller{Defined: true, Function: "main.run", File: "/app/main.go", Line: 42}
+	withCaller.Fields = nil
+	withCaller.Stack = "main.run\n\t/app/main.go:42\n"
+
+	tests := []struct {
+		name    string
+		encoder Encoder
+		entry   *Entry
+		expect  string
+	}{
+		{
+			name:    "text",
+			encoder: NewTextEncoder(EncoderConfig{}),
+			entry:   entry,
+			expect:  `2025/03/19 10:04:05.067 host-1 WARN slow query sql="select \"a\"" rows=3 ratio=0.25 cached=true cost=1.5s tags="[a b]"` + "\n",
+		},
+		{
+			name:    "text with caller and stack",
+			encoder: NewTextEncoder(EncoderConfig{TimeFormat: time.RFC3339, Location: time.UTC}),
+			entry:   &withCaller,
+			expect:  "2025-03-19T02:04:05Z host-1 WARN func:main.run file:/app/main.go line:42 slow query\nmain.run\n\t/app/main.go:42\n",
+		},
+		{
+			name:    "logfmt",
+			encoder: NewLogfmtEncoder(EncoderConfig{}),
+			entry:   entry,
+			expect:  `time="2025/03/19 10:04:05.067" level=WARN host=host-1 msg="slow query" sql="select \"a\"" rows=3 ratio=0.25 cached=true cost=1.5s tags="[a b]"` + "\n",
+		},
+		{
+			name:    "logfmt with caller and stack",
+			encoder: NewLogfmtEncoder(EncoderConfig{TimeFormat: TimeFormatUnix}),
+			entry:   &withCaller,
+			expect:  `time=1742349845 level=WARN host=host-1 msg="slow query" caller=/app/main.go:42 func=main.run stack="main.run\n\t/app/main.go:42\n"` + "\n",
+		},
+		{
+			name:    "json",
+			encoder: NewJSONEncoder(EncoderConfig{}),
+			entry:   entry,
+			expect:  `{"time":"2025/03/19 10:04:05.067","level":"WARN","host":"host-1","msg":"slow query","sql":"select \"a\"","rows":3,"ratio":0.25,"cached":true,"cost":"1.5s","tags":["a","b"]}` + "\n",
+		},
+		{
+			name:    "json with caller and stack",
+			encoder: NewJSONEncoder(EncoderConfig{TimeFormat: TimeFormatUnixMilli}),
+			entry:   &withCaller,
+			expect:  `{"time":1742349845067,"level":"WARN","host":"host-1","msg":"slow query","caller":"/app/main.go:42","func":"main.run","stack":"main.run\n\t/app/main.go:42\n"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := string(tt.encoder.Encode(nil, tt.entry)); got != tt.expect {
+			t.Fatalf("%s:\nexpected %s\ngot      %s", tt.name, tt.expect, got)
+		}
+	}
+}
+
+func TestJSONEncoderValid(t *testing.T) {
+	entry := &Entry{
+		Time:    time.Now(),
+		Level:   LevelInfo,
+		Message: "ctrl\x01 \"quote\" \\ 中文 \xff",
+		Fields: []Field{
+			Float64("nan", math.NaN()),
+			Time("at", time.Unix(0, 0).UTC()),
+			Any("chan", make(chan int)),
+			Any("err", errors.New("boom")),
+		},
+	}
+
+	var m map[string]interface{}
+	line := NewJSONEncoder(EncoderConfig{}).Encode(nil, entry)
+	if err := json.Unmarshal(line, &m); err != nil {
+		t.Fatalf("invalid json %s, %v", line, err)
+	}
+
+	if m["msg"] != "ctrl\x01 \"quote\" \\ 中文 \ufffd" || m["nan"] != "NaN" || m["at"] != "1970-01-01T00:00:00Z" || m["err"] != "boom" {
+		t.Fatalf("unexpected values %v", m)
+	}
+}
+
+func TestSetEncoder(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewTcLog(dir, "json", "debug")
+	if err != nil {
+		t.Fatalf("logger open failed, %v", err)
+	}
+	logger.SetEncoder(NewJSONEncoder(EncoderConfig{Location: time.UTC}))
+	logger.EnableFuncCallDepth(true)
+	logger.EnableStacktrace("error")
+
+	logger.Info("login", String("uid", "u1"), Int("age", 18))
+	logger.Error("failed", Err(errors.New("boom")))
+	logger.Close()
+
+	tests := []struct {
+		file   string
+		msg    string
+		fields map[string]interface{}
+		stack  bool
+	}{
+		{file: "json.log", msg: "login", fields: map[string]interface{}{"uid": "u1", "age": float64(18)}},
+		{file: "json.log.wf", msg: "failed", fields: map[string]interface{}{"error": "boom"}, stack: true},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatalf("read %s failed, %v", tt.file, err)
+		}
+
+		var m map[string]interface{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("%s: invalid json %s", tt.file, data)
+		}
+
+		if m["msg"] != tt.msg || !strings.Contains(m["caller"].(string), "encoder_test.go:") {
+			t.Fatalf("%s: unexpected entry %v", tt.file, m)
+		}
+		for key, value := range tt.fields {
+			if m[key] != value {
+				t.Fatalf("%s: expected %s=%v, got %v", tt.file, key, value, m[key])
+			}
+		}
+
+		stack, _ := m["stack"].(string)
+		if tt.stack != strings.HasPrefix(stack, "github.com/xkeyideal/gokit/tclog.TestSetEncoder") {
+			t.Fatalf("%s: unexpected stack %q", tt.file, stack)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package tclog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+type fieldKind uint8
+
+const (
+	skipKind fieldKind = iota
+	stringKind
+	intKind
+	uintKind
+	floatKind
+	boolKind
+	durationKind
+	timeKind
+	anyKind
+)
+
+// Field 结构化日志的字段，使用String、Int、Err等函数构造
+type Field struct {
+	Key string
+
+	kind fieldKind
+	i    int64
+	s    string
+	v    interface{}
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringKind, s: value}
+}
+
+func Int(key string, value int) Field {
+	return Int64(key, int64(value))
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: intKind, i: value}
+}
+
+func Uint64(key string, value uint64) Field {
+	return Field{Key: key, kind: uintKind, i: int64(value)}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: floatKind, i: int64(math.Float64bits(value))}
+}
+
+func Bool(key string, value bool) Field {
+	f := Field{Key: key, kind: boolKind}
+	if value {
+		f.i = 1
+	}
+	return f
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationKind, i: int64(value)}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, kind: timeKind, v: value}
+}
+
+// Err 使用error作为key，err为nil时忽略该字段
+func Err(err error) Field {
+	if err == nil {
+		return Field{kind: skipKind}
+	}
+	// 构造时即取出错误信息，避免异步写入时err已被修改
+	return Field{Key: "error", kind: stringKind, s: err.Error()}
+}
+
+// Any 任意类型的值，文本格式使用%v输出，值在写入前不应被修改
+func Any(key string, value interface{}) Field {
+	switch v := value.(type) {
+	case string:
+		return String(key, v)
+	case int:
+		return Int(key, v)
+	case int64:
+		return Int64(key, v)
+	case uint64:
+		return Uint64(key, v)
+	case float64:
+		return Float64(key, v)
+	case bool:
+		return Bool(key, v)
+	case time.Duration:
+		return Duration(key, v)
+	case time.Time:
+		return Time(key, v)
+	case error:
+		f := Err(v)
+		f.Key = key
+		return f
+	}
+	return Field{Key: key, kind: anyKind, v: value}
+}
+
+// Value 返回字段的值
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case stringKind:
+		return f.s
+	case intKind:
+		return f.i
+	case uintKind:
+		return uint64(f.i)
+	case floatKind:
+		return math.Float64frombits(uint64(f.i))
+	case boolKind:
+		return f.i == 1
+	case durationKind:
+		return time.Duration(f.i)
+	case timeKind, anyKind:
+		return f.v
+	}
+	return nil
+}
+
+// appendText 以key=value的形式追加字段，值含有空格、引号等字符时加引号
+func (f Field) appendText(buf []byte) []byte {
+	buf = append(buf, f.Key...)
+	buf = append(buf, '=')
+
+	switch f.kind {
+	case stringKind:
+		return appendTextValue(buf, f.s)
+	case intKind:
+		return strconv.AppendInt(buf, f.i, 10)
+	case uintKind:
+		return strconv.AppendUint(buf, uint64(f.i), 10)
+	case floatKind:
+		return strconv.AppendFloat(buf, math.Float64frombits(uint64(f.i)), 'g', -1, 64)
+	case boolKind:
+		return strconv.AppendBool(buf, f.i == 1)
+	case durationKind:
+		return append(buf, time.Duration(f.i).String()...)
+	case timeKind:
+		return f.v.(time.Time).AppendFormat(buf, time.RFC3339Nano)
+	default:
+		return appendTextValue(buf, fmt.Sprintf("%v", f.v))
+	}
+}
+
+func appendTextValue(buf []byte, s string) []byte {
+	if needQuote(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFields 按顺序追加字段，字段之间以空格分隔
+func appendFields(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		if f.kind == skipKind {
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = f.appendText(buf)
+	}
+	return buf
+}
+
+// splitFields 将末尾的Field与格式化参数分开
+func splitFields(v []interface{}) ([]interface{}, []Field) {
+	n := len(v)
+	for n > 0 {
+		if _, ok := v[n-1].(Field); !ok {
+			break
+		}
+		n--
+	}
+
+	if n == len(v) {
+		return v, nil
+	}
+
+	fields := make([]Field, 0, len(v)-n)
+	for _, f := range v[n:] {
+		fields = append(fields, f.(Field))
+	}
+	return v[:n], fields
+}
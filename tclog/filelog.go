@@ -6,9 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 )
@@ -16,25 +14,36 @@ import (
 type FileLog struct {
 	lock *sync.Mutex
 
-	maxLines               int
-	normalMaxLinesCurLines int
-	errMaxLinesCurLines    int
-
-	// Rotate at size
-	maxSize              int
-	normalMaxSizeCurSize int
-	errMaxSizeCurSize    int
+	// Rotate at lines or size
+	maxLines int
+	maxSize  int
 
 	// Rotate daily
-	daily               bool
-	maxDays             int64
-	dailyNormalOpenDate int
-	dailyErrOpenDate    int
+	daily   bool
+	maxDays int64
 
 	rotate bool
 
-	file     *os.File
-	errFile  *os.File
+	encoder Encoder
+
+	// 缓冲写入，bufferSize为0时每条日志直接写入文件
+	bufferSize    int
+	flushInterval time.Duration
+	flushing      bool
+
+	// 切分文件的压缩及清理，由cleanupLoop串行执行
+	compression  Compression
+	maxFiles     int
+	maxTotalSize int64
+	cleanupCh    chan struct{}
+	cleanupLock  sync.Mutex
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	// 输出文件及各级别写入的文件，由SetRoutes设置
+	outputs  []*fileOutput
+	routes   [LevelFatal + 1][]*fileOutput
 	filepath string
 	filename string
 }
@@ -83,138 +92,257 @@ func fileExist(filepath string) bool {
 	return false
 }
 
+// reopenCheck 文件被删除或移走后重新创建
 func (fileLog *FileLog) reopenCheck() {
 	ticker := time.NewTicker(120 * time.Second)
+	defer ticker.Stop()
 
-	for range ticker.C {
-		normalLog := fileLog.filepath + "/" + fileLog.filename + ".log"
-		if !fileExist(normalLog) {
-			file, err := fileLog.openFile(normalLog)
-			if err == nil {
-				fileLog.lock.Lock()
-				if fileLog.file != nil {
-					fileLog.file.Close()
-				}
-				fileLog.file = file
-				fileLog.normalMaxSizeCurSize = 0
-				fileLog.normalMaxLinesCurLines = 0
-				fileLog.lock.Unlock()
-			}
+	for {
+		select {
+		case <-ticker.C:
+		case <-fileLog.closed:
+			return
 		}
 
-		warnLog := normalLog + ".wf"
-		if !fileExist(warnLog) {
-			errFile, err := fileLog.openFile(warnLog)
-			if err == nil {
-				fileLog.lock.Lock()
-				if fileLog.errFile != nil {
-					fileLog.errFile.Close()
-				}
-				fileLog.errFile = errFile
-				fileLog.errMaxSizeCurSize = 0
-				fileLog.errMaxLinesCurLines = 0
-				fileLog.lock.Unlock()
+		fileLog.lock.Lock()
+		select {
+		case <-fileLog.closed:
+			fileLog.lock.Unlock()
+			return
+		default:
+		}
+
+		// 切分时打开失败的文件也在这里重试
+		for _, o := range fileLog.outputs {
+			if o.file != nil && fileExist(fileLog.outputPath(o)) {
+				continue
+			}
+
+			if o.file != nil {
+				fileLog.flushBuffer(o)
+				o.file.Close()
+				o.file = nil
+			}
+			if err := fileLog.openOutput(o); err != nil {
+				fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", fileLog.filename, err)
 			}
 		}
+		fileLog.lock.Unlock()
 	}
-
 }
 
 func newFileLog() *FileLog {
 	return &FileLog{
-		lock:     &sync.Mutex{},
-		filename: "log_filename",
-		maxLines: FileDefMaxLines,
-		maxSize:  FileDefMaxSize,
-		daily:    true,
-		maxDays:  FileDefMaxDays,
-		rotate:   true,
+		lock:      &sync.Mutex{},
+		filename:  "log_filename",
+		maxLines:  FileDefMaxLines,
+		maxSize:   FileDefMaxSize,
+		daily:     true,
+		maxDays:   FileDefMaxDays,
+		rotate:    true,
+		encoder:   NewTextEncoder(EncoderConfig{}),
+		closed:    make(chan struct{}),
+		cleanupCh: make(chan struct{}, 1),
 	}
 }
 
-func (fileLog *FileLog) openFile(filename string) (*os.File, error) {
+// NewFileLog 创建写入filepath/filename.log及filename.log.wf的日志文件，
+// 默认按天、行数及大小切分并保留7天，切分文件的压缩及清理在后台进行，可作为Sink使用
+func NewFileLog(filepath, filename string) (*FileLog, error) {
+	return NewFileLogWithRoutes(filepath, filename, DefaultFileRoutes()...)
+}
 
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+// NewFileLogWithRoutes 与NewFileLog相同，按routes将各级别的日志写入对应的文件
+func NewFileLogWithRoutes(filepath, filename string, routes ...FileRoute) (*FileLog, error) {
+	isDir, err := IsDir(filepath)
+	if err != nil || !isDir {
+		err = os.MkdirAll(filepath, 0755)
+		if err != nil {
+			return nil, NewError("Mkdir failed, err:%v", err)
+		}
+	}
 
-	if err != nil {
-		return nil, NewError("open %s failed, err:%v", filename, err)
+	fileLog := newFileLog()
+	fileLog.filename = filename
+	fileLog.filepath = filepath
+
+	if err := fileLog.SetRoutes(routes...); err != nil {
+		return nil, err
 	}
 
-	return file, err
+	go fileLog.reopenCheck()
+	go fileLog.cleanupLoop()
+	fileLog.triggerCleanup()
+
+	return fileLog, nil
+}
+
+// SetEncoder 设置编码格式，默认为NewTextEncoder(EncoderConfig{})
+func (fileLog *FileLog) SetEncoder(enc Encoder) {
+	fileLog.lock.Lock()
+	fileLog.encoder = enc
+	fileLog.lock.Unlock()
 }
 
-func (fileLog *FileLog) startLogger(info, warn bool) error {
+func (fileLog *FileLog) SetMaxDays(day int64) {
+	fileLog.lock.Lock()
+	fileLog.maxDays = day
+	fileLog.lock.Unlock()
+}
 
-	normalLog := fileLog.filepath + "/" + fileLog.filename + ".log"
+func (fileLog *FileLog) SetMaxLines(line int) {
+	fileLog.lock.Lock()
+	fileLog.maxLines = line
+	fileLog.lock.Unlock()
+}
 
-	if info {
-		file, err := fileLog.openFile(normalLog)
-		if err != nil {
-			return err
+func (fileLog *FileLog) SetMaxSize(size int) {
+	fileLog.lock.Lock()
+	fileLog.maxSize = size
+	fileLog.lock.Unlock()
+}
+
+func (fileLog *FileLog) EnableRotate(flag bool) {
+	fileLog.lock.Lock()
+	fileLog.rotate = flag
+	fileLog.lock.Unlock()
+}
+
+func (fileLog *FileLog) EnableDaily(flag bool) {
+	fileLog.lock.Lock()
+	fileLog.daily = flag
+	fileLog.lock.Unlock()
+}
+
+// EnableBuffer 缓冲写入，缓冲超过size字节或距上次写入超过interval时写入文件，
+// Flush、Close及Error、Fatal级别的日志会立即写入，size<=0时关闭缓冲
+func (fileLog *FileLog) EnableBuffer(size int, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	fileLog.lock.Lock()
+	defer fileLog.lock.Unlock()
+
+	if size <= 0 {
+		fileLog.bufferSize = 0
+		fileLog.flushBuffers()
+		return
+	}
+
+	fileLog.bufferSize = size
+	fileLog.flushInterval = interval
+
+	if !fileLog.flushing {
+		fileLog.flushing = true
+		go fileLog.flushLoop()
+	}
+}
+
+// flushLoop 定时将缓冲写入文件
+func (fileLog *FileLog) flushLoop() {
+	for {
+		fileLog.lock.Lock()
+		interval := fileLog.flushInterval
+		fileLog.lock.Unlock()
+
+		select {
+		case <-time.After(interval):
+		case <-fileLog.closed:
+			return
 		}
-		if fileLog.file != nil {
-			fileLog.file.Close()
+
+		fileLog.lock.Lock()
+		if err := fileLog.flushBuffers(); err != nil {
+			fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", fileLog.filename, err)
 		}
-		fileLog.file = file
+		fileLog.lock.Unlock()
 	}
+}
 
-	if warn {
-		warnLog := normalLog + ".wf"
-		errFile, err := fileLog.openFile(warnLog)
-		if err != nil {
-			fileLog.file.Close()
-			fileLog.file = nil
+// write 写入或放入缓冲，调用方需持有锁
+func (fileLog *FileLog) write(o *fileOutput, msg []byte) error {
+	if fileLog.bufferSize <= 0 {
+		_, err := o.file.Write(msg)
+		return err
+	}
+
+	if len(o.buf)+len(msg) > fileLog.bufferSize {
+		if err := fileLog.flushBuffer(o); err != nil {
 			return err
 		}
-		if fileLog.errFile != nil {
-			fileLog.errFile.Close()
-		}
-		fileLog.errFile = errFile
 	}
 
-	return fileLog.initFd(info, warn)
+	if len(msg) >= fileLog.bufferSize {
+		_, err := o.file.Write(msg)
+		return err
+	}
+
+	o.buf = append(o.buf, msg...)
+	return nil
 }
 
-func (fileLog *FileLog) initFd(info, warn bool) error {
+// flushBuffer 将缓冲写入文件，写入失败时丢弃缓冲，调用方需持有锁
+func (fileLog *FileLog) flushBuffer(o *fileOutput) error {
+	if len(o.buf) == 0 || o.file == nil {
+		return nil
+	}
 
-	if info {
-		fileLog.dailyNormalOpenDate = time.Now().Day()
-		normalFd := fileLog.file
-		fInfo, err := normalFd.Stat()
-		if err != nil {
-			return NewError("get normalfile stat err:%v", err)
-		}
-		fileLog.normalMaxSizeCurSize = int(fInfo.Size())
-		fileLog.normalMaxLinesCurLines = 0
-		if fInfo.Size() > 0 {
-			normalLog := fileLog.filepath + "/" + fileLog.filename + ".log"
-			count, err := fileLog.lines(normalLog)
-			if err != nil {
-				return err
-			}
-			fileLog.normalMaxLinesCurLines = count
-		}
+	_, err := o.file.Write(o.buf)
+	o.buf = o.buf[:0]
+	return err
+}
+
+func (fileLog *FileLog) flushBuffers() error {
+	var errs []error
+	for _, o := range fileLog.outputs {
+		errs = append(errs, fileLog.flushBuffer(o))
+	}
+	return errors.Join(errs...)
+}
+
+func (fileLog *FileLog) outputPath(o *fileOutput) string {
+	return fileLog.filepath + "/" + fileLog.filename + o.suffix
+}
+
+func (fileLog *FileLog) openFile(filename string) (*os.File, error) {
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return nil, NewError("open %s failed, err:%v", filename, err)
+	}
+
+	return file, err
+}
+
+// openOutput 打开文件并根据已有内容初始化切分统计，调用方需持有锁
+func (fileLog *FileLog) openOutput(o *fileOutput) error {
+	name := fileLog.outputPath(o)
+	file, err := fileLog.openFile(name)
+	if err != nil {
+		return err
 	}
 
-	if warn {
-		fileLog.dailyErrOpenDate = time.Now().Day()
-		errFd := fileLog.errFile
-		fInfo, err := errFd.Stat()
+	fInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return NewError("get %s stat err:%v", name, err)
+	}
+
+	count := 0
+	if fInfo.Size() > 0 {
+		count, err = fileLog.lines(name)
 		if err != nil {
-			return NewError("get errfile stat err:%v", err)
-		}
-		fileLog.errMaxSizeCurSize = int(fInfo.Size())
-		fileLog.errMaxLinesCurLines = 0
-		if fInfo.Size() > 0 {
-			errLog := fileLog.filepath + "/" + fileLog.filename + ".log.wf"
-			count, err := fileLog.lines(errLog)
-			if err != nil {
-				return err
-			}
-			fileLog.errMaxLinesCurLines = count
+			file.Close()
+			return err
 		}
 	}
 
+	o.file = file
+	o.openDate = time.Now().In(cstLocal).Day()
+	o.curSize = int(fInfo.Size())
+	o.curLines = count
 	return nil
 }
 
@@ -245,135 +373,113 @@ func (fileLog *FileLog) lines(filepath string) (int, error) {
 	return count, nil
 }
 
-func (fileLog *FileLog) needRotate(size int, day int, normal bool) bool {
+func (fileLog *FileLog) needRotate(o *fileOutput, day int) bool {
+	return (fileLog.maxLines > 0 && o.curLines >= fileLog.maxLines) ||
+		(fileLog.maxSize > 0 && o.curSize >= fileLog.maxSize) ||
+		(fileLog.daily && day != o.openDate)
+}
 
-	curLines := fileLog.normalMaxLinesCurLines
-	curSize := fileLog.normalMaxSizeCurSize
-	if normal == false {
-		curLines = fileLog.errMaxLinesCurLines
-		curSize = fileLog.errMaxSizeCurSize
-	}
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+// WriteMsg 保留兼容，使用文本格式之外的编码器时msg作为消息
+func (fileLog *FileLog) WriteMsg(hostname string, when time.Time, msg string, level int) error {
+	return fileLog.WriteEntry(&Entry{
+		Time:    when,
+		Level:   level,
+		Host:    hostname,
+		Message: msg,
+	})
+}
 
-	ok := false
-	if normal {
-		ok = (fileLog.maxLines > 0 && curLines >= fileLog.maxLines) ||
-			(fileLog.maxSize > 0 && curSize >= fileLog.maxSize) ||
-			(fileLog.daily && day != fileLog.dailyNormalOpenDate)
-	} else {
-		ok = (fileLog.maxLines > 0 && curLines >= fileLog.maxLines) ||
-			(fileLog.maxSize > 0 && curSize >= fileLog.maxSize) ||
-			(fileLog.daily && day != fileLog.dailyErrOpenDate)
+// WriteEntry 编码并写入一条日志，按SetRoutes写入对应级别的文件，默认Warn及以上级别写入.wf文件
+func (fileLog *FileLog) WriteEntry(e *Entry) error {
+	if e.Level < 0 || e.Level > LevelFatal {
+		return NewError("invalid level %d", e.Level)
 	}
 
-	return ok
-}
+	bufp := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufp)
 
-func (fileLog *FileLog) WriteMsg(hostname string, when time.Time, msg string, level int) error {
-	h, d := formatTimeHeader(when)
-
-	msg = fmt.Sprintf("%s %s %s %s\n", strings.TrimSpace(string(h)), hostname, levelTextArray[level], msg)
-	var msgLength int
-	msgLength = len(msg)
-
-	var err error
-	if fileLog.rotate {
-		if level >= LevelWarn {
-			fileLog.lock.Lock()
-			if fileLog.needRotate(msgLength, d, false) {
-				if err = fileLog.doRotate(when, false); err != nil {
-					fmt.Fprintf(os.Stderr, "FileLogErrWriter(%q): %s\n", fileLog.filename, err)
-				}
+	fileLog.lock.Lock()
+	defer fileLog.lock.Unlock()
+
+	*bufp = fileLog.encoder.Encode((*bufp)[:0], e)
+	msg := *bufp
+	msgLength := len(msg)
+	d := e.Time.In(cstLocal).Day()
+
+	var errs []error
+	for _, o := range fileLog.routes[e.Level] {
+		if o.file == nil {
+			continue
+		}
+
+		if fileLog.rotate && fileLog.needRotate(o, d) {
+			if err := fileLog.doRotate(o, e.Time); err != nil {
+				fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", fileLog.filename+o.suffix, err)
+				errs = append(errs, err)
 			}
-			fileLog.lock.Unlock()
-		} else {
-			fileLog.lock.Lock()
-			if fileLog.needRotate(msgLength, d, true) {
-				if err = fileLog.doRotate(when, true); err != nil {
-					fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", fileLog.filename, err)
-				}
+			if o.file == nil {
+				continue
 			}
-			fileLog.lock.Unlock()
 		}
-	}
 
-	fileLog.lock.Lock()
-	if level >= LevelWarn {
-		_, err = fileLog.errFile.Write([]byte(msg))
-		if err == nil {
-			fileLog.errMaxLinesCurLines++
-			fileLog.errMaxSizeCurSize += msgLength
-		}
-	} else {
-		_, err = fileLog.file.Write([]byte(msg))
+		// 放入缓冲的日志也计入当前文件，切分前会先写入缓冲
+		err := fileLog.write(o, msg)
 		if err == nil {
-			fileLog.normalMaxLinesCurLines++
-			fileLog.normalMaxSizeCurSize += msgLength
+			o.curLines++
+			o.curSize += msgLength
 		}
+		errs = append(errs, err)
 	}
-	fileLog.lock.Unlock()
-	return err
-}
 
-func (fileLog *FileLog) doRotate(logTime time.Time, normal bool) error {
-	filepath := ""
-	if normal == true {
-		filepath = fileLog.filepath + "/" + fileLog.filename + ".log"
-	} else {
-		filepath = fileLog.filepath + "/" + fileLog.filename + ".log.wf"
+	if e.Level >= LevelError && fileLog.bufferSize > 0 {
+		errs = append(errs, fileLog.flushBuffers())
 	}
+
+	return errors.Join(errs...)
+}
+
+// doRotate 将文件重命名为filename.日期[.序号]后缀并重新打开，调用方需持有锁
+func (fileLog *FileLog) doRotate(o *fileOutput, logTime time.Time) error {
+	filepath := fileLog.outputPath(o)
 	_, err := os.Lstat(filepath)
 	if err != nil {
 		return err
 	}
 	// file exists
-	// Find the next available number
-	num := 1
-	fName := ""
-	if fileLog.maxLines > 0 || fileLog.maxSize > 0 {
-		for ; err == nil && num < MaxFileNum; num++ {
-			if normal == true {
-				fName = fileLog.filepath + "/" + fileLog.filename + fmt.Sprintf(".%s.%03d.log", logTime.Format("2006-01-02"), num)
-			} else {
-				fName = fileLog.filepath + "/" + fileLog.filename + fmt.Sprintf(".%s.%03d.log.wf", logTime.Format("2006-01-02"), num)
+	// Find the next available number, 已压缩的文件同样占用序号
+	prefix := fileLog.filepath + "/" + fileLog.filename + "." + logTime.Format("2006-01-02")
+	fName := prefix + o.suffix
+	if fileLog.maxLines > 0 || fileLog.maxSize > 0 || rotatedExists(fName) {
+		for num := 1; ; num++ {
+			fName = prefix + fmt.Sprintf(".%03d", num) + o.suffix
+			if !rotatedExists(fName) {
+				break
 			}
-			_, err = os.Lstat(fName)
-		}
-	} else {
-		if normal == true {
-			fName = fmt.Sprintf("%s/%s.%s.log", fileLog.filepath, fileLog.filename, logTime.Format("2006-01-02"))
-		} else {
-			fName = fmt.Sprintf("%s/%s.%s.log.wf", fileLog.filepath, fileLog.filename, logTime.Format("2006-01-02"))
 		}
-		_, err = os.Lstat(fName)
-	}
-
-	// return error if the last file checked still existed
-	if err == nil {
-		return NewError("Rotate: Cannot find free log number to rename %s", fileLog.filename)
 	}
 
 	// close fileWriter before rename
-	if normal == true {
-		fileLog.file.Close()
-	} else {
-		fileLog.errFile.Close()
+	if err := fileLog.flushBuffer(o); err != nil {
+		fmt.Fprintf(os.Stderr, "FileLogWriter(%q): %s\n", fileLog.filename, err)
 	}
+	o.file.Close()
+	o.file = nil
 
 	// Rename the file to its new found name
 	// even if occurs error,we MUST guarantee to  restart new logger
 	renameErr := os.Rename(filepath, fName)
 
-	info, warn := false, false
-	if normal {
-		info = true
-	} else {
-		warn = true
-	}
-
 	// re-start logger
-	startLoggerErr := fileLog.startLogger(info, warn)
+	startLoggerErr := fileLog.openOutput(o)
 
-	go fileLog.deleteOldLog()
+	fileLog.triggerCleanup()
 
 	if renameErr != nil {
 		return NewError("Rotate: %s", renameErr.Error())
@@ -385,49 +491,45 @@ func (fileLog *FileLog) doRotate(logTime time.Time, normal bool) error {
 	return nil
 }
 
-func (fileLog *FileLog) deleteOldLog() {
-	filepath.Walk(fileLog.filepath, func(path string, info os.FileInfo, err error) (returnErr error) {
-		if err != nil {
-			return
-		}
-
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Fprintf(os.Stderr, "Unable to delete old log '%s', error: %v\n", path, r)
-			}
-		}()
+// Destroy 保留兼容，与Close相同
+func (fileLog *FileLog) Destroy() {
+	fileLog.Close()
+}
 
-		if !info.IsDir() && info.ModTime().Unix() < (time.Now().Unix()-86400*fileLog.maxDays) {
-			if strings.HasPrefix(filepath.Base(path), fileLog.filename) &&
-				(strings.HasSuffix(filepath.Base(path), ".log") || strings.HasSuffix(filepath.Base(path), ".log.wf")) {
-				os.Remove(path)
-			}
-		}
-		return
+// Close 关闭日志文件
+func (fileLog *FileLog) Close() error {
+	fileLog.closeOnce.Do(func() {
+		close(fileLog.closed)
+		// 等待正在进行的压缩及清理结束
+		fileLog.cleanupLock.Lock()
+		fileLog.cleanupLock.Unlock()
 	})
-}
 
-func (fileLog *FileLog) Destroy() {
-	if fileLog.errFile != nil {
-		fileLog.errFile.Close()
-		fileLog.errFile = nil
-	}
-	if fileLog.file != nil {
-		fileLog.file.Close()
-		fileLog.file = nil
+	fileLog.lock.Lock()
+	defer fileLog.lock.Unlock()
+
+	errs := []error{fileLog.flushBuffers()}
+	for _, o := range fileLog.outputs {
+		if o.file != nil {
+			errs = append(errs, o.file.Close())
+			o.file = nil
+		}
 	}
+	return errors.Join(errs...)
 }
 
-// Flush flush file logger.
-// there are no buffering messages in file logger in memory.
-// flush file means sync file from disk.
-func (fileLog *FileLog) Flush() {
-	if fileLog.errFile != nil {
-		fileLog.errFile.Sync()
-	}
-	if fileLog.file != nil {
-		fileLog.file.Sync()
+// Flush 将缓冲写入文件并Sync
+func (fileLog *FileLog) Flush() error {
+	fileLog.lock.Lock()
+	defer fileLog.lock.Unlock()
+
+	errs := []error{fileLog.flushBuffers()}
+	for _, o := range fileLog.outputs {
+		if o.file != nil {
+			errs = append(errs, o.file.Sync())
+		}
 	}
+	return errors.Join(errs...)
 }
 
 const (
@@ -0,0 +1,189 @@
+package tclog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fileSize(t *testing.T, name string) int {
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("stat %s failed, %v", name, err)
+	}
+	return int(info.Size())
+}
+
+func TestFileLogBuffer(t *testing.T) {
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "buffer")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+	defer fileLog.Close()
+
+	fileLog.EnableBuffer(1024, time.Hour)
+
+	normal := filepath.Join(dir, "buffer.log")
+	wf := filepath.Join(dir, "buffer.log.wf")
+	entry := func(level int, msg string) *Entry {
+		return &Entry{Time: time.Now().In(cstLocal), Level: level, Host: "h", Message: msg}
+	}
+
+	tests := []struct {
+		name       string
+		action     func()
+		normalSize func(cur int) bool
+		wfSize     func(cur int) bool
+	}{
+		{
+			name:       "info is buffered",
+			action:     func() { fileLog.WriteEntry(entry(LevelInfo, "first")) },
+			normalSize: func(cur int) bool { return cur == 0 },
+		},
+		{
+			name:   "warn is buffered",
+			action: func() { fileLog.WriteEntry(entry(LevelWarn, "warn")) },
+			wfSize: func(cur int) bool { return cur == 0 },
+		},
+		{
+			name:       "error flushes all buffers",
+			action:     func() { fileLog.WriteEntry(entry(LevelError, "error")) },
+			normalSize: func(cur int) bool { return cur == fileLog.outputs[0].curSize },
+			wfSize:     func(cur int) bool { return cur == fileLog.outputs[1].curSize },
+		},
+		{
+			name: "size threshold",
+			action: func() {
+				for i := 0; i < 30; i++ {
+					fileLog.WriteEntry(entry(LevelInfo, strings.Repeat("x", 64)))
+				}
+			},
+			normalSize: func(cur int) bool { return cur > 0 && cur < fileLog.outputs[0].curSize },
+		},
+		{
+			name:       "flush",
+			action:     func() { fileLog.Flush() },
+			normalSize: func(cur int) bool { return cur == fileLog.outputs[0].curSize },
+		},
+		{
+			name:       "large message bypasses buffer",
+			action:     func() { fileLog.WriteEntry(entry(LevelInfo, strings.Repeat("y", 2048))) },
+			normalSize: func(cur int) bool { return cur == fileLog.outputs[0].curSize },
+		},
+	}
+
+	for _, tt := range tests {
+		tt.action()
+		if tt.normalSize != nil && !tt.normalSize(fileSize(t, normal)) {
+			t.Fatalf("%s: unexpected normal file size %d, accounted %d", tt.name, fileSize(t, normal), fileLog.outputs[0].curSize)
+		}
+		if tt.wfSize != nil && !tt.wfSize(fileSize(t, wf)) {
+			t.Fatalf("%s: unexpected wf file size %d, accounted %d", tt.name, fileSize(t, wf), fileLog.outputs[1].curSize)
+		}
+	}
+	if fileLog.outputs[0].curLines != 32 {
+		t.Fatalf("expected 32 lines accounted, got %d", fileLog.outputs[0].curLines)
+	}
+}
+
+func TestFileLogBufferInterval(t *testing.T) {
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "interval")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+	defer fileLog.Close()
+
+	fileLog.EnableBuffer(1<<20, 20*time.Millisecond)
+	fileLog.WriteEntry(&Entry{Time: time.Now().In(cstLocal), Level: LevelInfo, Message: "tick"})
+
+	normal := filepath.Join(dir, "interval.log")
+	deadline := time.Now().Add(time.Second)
+	for fileSize(t, normal) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fileSize(t, normal) == 0 {
+		t.Fatalf("buffer should be flushed after interval")
+	}
+}
+
+func TestFileLogBufferRotate(t *testing.T) {
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "rotate")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+	fileLog.SetMaxSize(1000)
+	fileLog.EnableBuffer(4096, time.Hour)
+
+	line := strings.Repeat("z", 100)
+	for i := 0; i < 25; i++ {
+		fileLog.WriteEntry(&Entry{Time: time.Now().In(cstLocal), Level: LevelInfo, Host: "h", Message: line})
+	}
+	fileLog.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "rotate.*.log"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 rotated files, got %v", files)
+	}
+	files = append(files, filepath.Join(dir, "rotate.log"))
+
+	lines := 0
+	for _, name := range files {
+		data, _ := os.ReadFile(name)
+		if len(data) > 1000+len(line)+64 {
+			t.Fatalf("%s exceeds max size, %d", name, len(data))
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+
+	if lines != 25 {
+		t.Fatalf("expected 25 lines across files, got %d", lines)
+	}
+}
+
+func BenchmarkFileLogWrite(b *testing.B) {
+	benchmarks := []struct {
+		name       string
+		bufferSize int
+	}{
+		{name: "unbuffered", bufferSize: 0},
+		{name: "buffered", bufferSize: DefaultFileBufferSize},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			fileLog, err := NewFileLog(b.TempDir(), "bench")
+			if err != nil {
+				b.Fatalf("create file log failed, %v", err)
+			}
+			defer fileLog.Close()
+
+			fileLog.EnableDaily(false)
+			fileLog.SetMaxLines(0)
+			fileLog.SetMaxSize(0)
+			fileLog.EnableBuffer(bm.bufferSize, time.Second)
+
+			entry := &Entry{
+				Time:    time.Now().In(cstLocal),
+				Level:   LevelInfo,
+				Host:    "bench-host",
+				Message: "request handled",
+				Fields:  []Field{String("path", "/api/v1/users"), Int("status", 200), Duration("cost", 3*time.Millisecond)},
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := fileLog.WriteEntry(entry); err != nil {
+					b.Fatalf("write failed, %v", err)
+				}
+			}
+			b.StopTimer()
+			fileLog.Flush()
+		})
+	}
+}
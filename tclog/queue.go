@@ -0,0 +1,269 @@
+package tclog
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQueueSize          = 100
+	DefaultDropReportInterval = 10 * time.Second
+)
+
+// OverflowPolicy 队列满时的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 默认，阻塞调用方直到队列有空位
+	OverflowDropNewest                       // 丢弃新日志
+	OverflowDropOldest                       // 丢弃队列中最早的日志
+	OverflowDropBelow                        // 丢弃低于DropLevel的日志，其余日志优先挤掉队列中低级别的日志，没有时阻塞
+)
+
+// QueueOptions 异步写入队列的配置
+type QueueOptions struct {
+	Size           int            // 队列长度，默认100
+	Policy         OverflowPolicy // 队列满时的处理方式，默认阻塞
+	DropLevel      string         // OverflowDropBelow时可丢弃的级别上限(不含)，默认warn
+	ReportInterval time.Duration  // 丢弃日志后，队列恢复时最多每隔多久输出一条"N messages dropped"，默认10s
+}
+
+// QueueStats 队列的状态
+type QueueStats struct {
+	Size           int
+	Len            int
+	Dropped        uint64            // 累计丢弃的日志数
+	DroppedByLevel map[string]uint64 // 按级别累计丢弃的日志数
+}
+
+// queue 日志的异步写入队列，由写入协程一次取出全部日志
+type queue struct {
+	lock     sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+
+	items          []*Entry
+	size           int
+	policy         OverflowPolicy
+	dropLevel      int
+	reportInterval time.Duration
+
+	dropped    [LevelFatal + 1]uint64
+	pending    [LevelFatal + 1]uint64 // 上次输出丢弃日志数之后丢弃的日志
+	lastReport time.Time
+
+	flushes []chan struct{}
+	closing bool
+}
+
+func newQueue() *queue {
+	q := &queue{
+		size:           DefaultQueueSize,
+		dropLevel:      LevelWarn,
+		reportInterval: DefaultDropReportInterval,
+	}
+	q.notEmpty = sync.NewCond(&q.lock)
+	q.notFull = sync.NewCond(&q.lock)
+	return q
+}
+
+// put 按溢出策略放入日志，日志被丢弃或队列已关闭时返回false
+func (q *queue) put(e *Entry) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		if q.closing {
+			return false
+		}
+
+		if len(q.items) < q.size {
+			q.push(e)
+			return true
+		}
+
+		switch q.policy {
+		case OverflowDropNewest:
+			q.drop(e.Level)
+			return false
+		case OverflowDropOldest:
+			q.evict(0)
+			q.push(e)
+			return true
+		case OverflowDropBelow:
+			if e.Level < q.dropLevel {
+				q.drop(e.Level)
+				return false
+			}
+			for i, item := range q.items {
+				if item.Level < q.dropLevel {
+					q.evict(i)
+					q.push(e)
+					return true
+				}
+			}
+		}
+
+		q.notFull.Wait()
+	}
+}
+
+func (q *queue) push(e *Entry) {
+	q.items = append(q.items, e)
+	q.notEmpty.Signal()
+}
+
+func (q *queue) evict(i int) {
+	e := q.items[i]
+	copy(q.items[i:], q.items[i+1:])
+	q.items[len(q.items)-1] = nil
+	q.items = q.items[:len(q.items)-1]
+
+	q.drop(e.Level)
+	releaseEntry(e)
+}
+
+func (q *queue) drop(level int) {
+	q.dropped[level]++
+	q.pending[level]++
+}
+
+// take 等待并取出队列中的全部日志及flush、close请求，batch用于复用内存
+func (q *queue) take(batch []*Entry) ([]*Entry, []chan struct{}, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for len(q.items) == 0 && len(q.flushes) == 0 && !q.closing {
+		q.notEmpty.Wait()
+	}
+
+	batch, q.items = q.items, batch[:0]
+	flushes := q.flushes
+	q.flushes = nil
+	q.notFull.Broadcast()
+
+	return batch, flushes, q.closing
+}
+
+func (q *queue) flush(done chan struct{}) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closing {
+		return false
+	}
+
+	q.flushes = append(q.flushes, done)
+	q.notEmpty.Signal()
+	return true
+}
+
+func (q *queue) close() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.closing {
+		return false
+	}
+
+	q.closing = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	return true
+}
+
+// takeDropped 队列已清空(或force)且距上次输出超过间隔时，返回上次输出之后丢弃的日志数
+func (q *queue) takeDropped(now time.Time, force bool) ([LevelFatal + 1]uint64, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	var pending [LevelFatal + 1]uint64
+	if q.pending == pending {
+		return pending, false
+	}
+
+	if !force && (len(q.items) > 0 || now.Sub(q.lastReport) < q.reportInterval) {
+		return pending, false
+	}
+
+	pending = q.pending
+	q.pending = [LevelFatal + 1]uint64{}
+	q.lastReport = now
+	return pending, true
+}
+
+// SetQueue 设置异步写入队列，运行时可修改，队列缩小时已在队列中的日志不会被丢弃
+func (log *TcLog) SetQueue(opts QueueOptions) {
+	if opts.Size <= 0 {
+		opts.Size = DefaultQueueSize
+	}
+
+	dropLevel := LevelWarn
+	if opts.DropLevel != "" {
+		dropLevel = log.levelFromStr(opts.DropLevel)
+	}
+
+	if opts.ReportInterval <= 0 {
+		opts.ReportInterval = DefaultDropReportInterval
+	}
+
+	q := log.queue
+	q.lock.Lock()
+	q.size = opts.Size
+	q.policy = opts.Policy
+	q.dropLevel = dropLevel
+	q.reportInterval = opts.ReportInterval
+	q.notFull.Broadcast()
+	q.lock.Unlock()
+}
+
+// QueueStats 返回队列长度及丢弃的日志数
+func (log *TcLog) QueueStats() QueueStats {
+	q := log.queue
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stats := QueueStats{
+		Size:           q.size,
+		Len:            len(q.items),
+		DroppedByLevel: make(map[string]uint64, len(q.dropped)),
+	}
+	for level, n := range q.dropped {
+		stats.Dropped += n
+		stats.DroppedByLevel[levelTextArray[level]] = n
+	}
+
+	return stats
+}
+
+// reportDropped 写入一条Warn日志说明丢弃了多少日志，直接写入各输出不经过队列
+func (log *TcLog) reportDropped(force bool) {
+	pending, ok := log.queue.takeDropped(time.Now(), force)
+	if !ok {
+		return
+	}
+
+	var total uint64
+	fields := make([]Field, 1, len(pending)+1)
+	for level, n := range pending {
+		if n == 0 {
+			continue
+		}
+		total += n
+		fields = append(fields, Uint64("dropped_"+strings.ToLower(levelTextArray[level]), n))
+	}
+	fields[0] = Uint64("dropped", total)
+
+	e := entryPool.Get().(*Entry)
+	e.Time = time.Now().In(cstLocal)
+	e.Level = LevelWarn
+	e.Host = log.hostname
+	e.Message = "tclog: " + strconv.FormatUint(total, 10) + " messages dropped"
+	e.Caller = Caller{}
+	e.Stack = ""
+	e.Fields = append(e.Fields[:0], fields...)
+
+	log.output(e)
+}
@@ -0,0 +1,183 @@
+package tclog
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateSink 第一次写入时阻塞直到gate关闭，用于填满队列
+type gateSink struct {
+	entered chan struct{}
+	gate    chan struct{}
+
+	lock sync.Mutex
+	msgs []string
+}
+
+func newGateSink() *gateSink {
+	return &gateSink{
+		entered: make(chan struct{}),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (s *gateSink) WriteEntry(e *Entry) error {
+	s.lock.Lock()
+	first := len(s.msgs) == 0
+	s.msgs = append(s.msgs, e.Message)
+	s.lock.Unlock()
+
+	if first {
+		close(s.entered)
+		<-s.gate
+	}
+	return nil
+}
+
+func (s *gateSink) Flush() error { return nil }
+func (s *gateSink) Close() error { return nil }
+
+func (s *gateSink) messages() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.msgs...)
+}
+
+func TestQueueOverflow(t *testing.T) {
+	type msg struct {
+		level int
+		text  string
+	}
+
+	tests := []struct {
+		name    string
+		opts    QueueOptions
+		msgs    []msg
+		blocked bool
+		written []string
+		dropped map[string]uint64
+	}{
+		{
+			name:    "block",
+			opts:    QueueOptions{Size: 2},
+			msgs:    []msg{{LevelInfo, "m1"}, {LevelInfo, "m2"}, {LevelInfo, "m3"}},
+			blocked: true,
+			written: []string{"m0", "m1", "m2", "m3"},
+		},
+		{
+			name:    "drop newest",
+			opts:    QueueOptions{Size: 2, Policy: OverflowDropNewest},
+			msgs:    []msg{{LevelInfo, "m1"}, {LevelInfo, "m2"}, {LevelError, "m3"}},
+			written: []string{"m0", "m1", "m2", "tclog: 1 messages dropped"},
+			dropped: map[string]uint64{"ERROR": 1},
+		},
+		{
+			name:    "drop oldest",
+			opts:    QueueOptions{Size: 2, Policy: OverflowDropOldest},
+			msgs:    []msg{{LevelInfo, "m1"}, {LevelInfo, "m2"}, {LevelInfo, "m3"}, {LevelInfo, "m4"}},
+			written: []string{"m0", "m3", "m4", "tclog: 2 messages dropped"},
+			dropped: map[string]uint64{"INFO": 2},
+		},
+		{
+			name:    "drop below level",
+			opts:    QueueOptions{Size: 2, Policy: OverflowDropBelow, DropLevel: "warn"},
+			msgs:    []msg{{LevelInfo, "m1"}, {LevelError, "m2"}, {LevelDebug, "m3"}, {LevelWarn, "m4"}},
+			written: []string{"m0", "m2", "m4", "tclog: 2 messages dropped"},
+			dropped: map[string]uint64{"DEBUG": 1, "INFO": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newGateSink()
+			logger := NewTcLogWithSinks("debug", SinkConfig{Sink: sink})
+			logger.SetQueue(tt.opts)
+
+			logger.Info("m0")
+			<-sink.entered
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for _, m := range tt.msgs {
+					logger.logf(m.level, 0, m.text, nil)
+				}
+			}()
+
+			select {
+			case <-done:
+				if tt.blocked {
+					t.Fatalf("caller should be blocked when queue is full")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tt.blocked {
+					t.Fatalf("caller should not be blocked")
+				}
+			}
+
+			close(sink.gate)
+			<-done
+			logger.Close()
+
+			written := sink.messages()
+			if len(written) != len(tt.written) {
+				t.Fatalf("expected %q, got %q", tt.written, written)
+			}
+			for i := range written {
+				if written[i] != tt.written[i] {
+					t.Fatalf("expected %q, got %q", tt.written, written)
+				}
+			}
+
+			stats := logger.QueueStats()
+			var total uint64
+			for level, n := range tt.dropped {
+				total += n
+				if stats.DroppedByLevel[level] != n {
+					t.Fatalf("expected %d %s dropped, got %v", n, level, stats.DroppedByLevel)
+				}
+			}
+			if stats.Dropped != total || stats.Size != tt.opts.Size {
+				t.Fatalf("unexpected stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestQueueFlushAndClose(t *testing.T) {
+	var buf syncBuffer
+	logger := NewTcLogWithSinks("info", SinkConfig{Sink: NewWriterSink(&buf, nil)})
+
+	logger.Info("before flush")
+	logger.Flush()
+	if n := buf.lines(); n != 1 {
+		t.Fatalf("flush should wait for queued messages, got %d lines", n)
+	}
+
+	logger.Close()
+	logger.Info("after close")
+	logger.Flush()
+	logger.Close()
+	if n := buf.lines(); n != 1 {
+		t.Fatalf("messages after close should be discarded, got %d lines", n)
+	}
+}
+
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return bytes.Count(b.buf.Bytes(), []byte{'\n'})
+}
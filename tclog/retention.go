@@ -0,0 +1,293 @@
+package tclog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 切分后的日志文件的压缩方式
+type Compression int
+
+const (
+	CompressNone Compression = iota
+	CompressGzip             // 压缩为.gz
+	CompressZstd             // 压缩为.zst
+)
+
+func (c Compression) ext() string {
+	switch c {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+var compressedExts = []string{".gz", ".zst"}
+
+// SetCompression 切分后的文件在后台压缩，压缩后保留原文件的修改时间，不影响按天数清理
+func (fileLog *FileLog) SetCompression(c Compression) {
+	fileLog.lock.Lock()
+	fileLog.compression = c
+	fileLog.lock.Unlock()
+}
+
+// SetMaxFiles 最多保留的切分文件数(包括.log.wf)，超过时删除最早的文件，0表示不限制
+func (fileLog *FileLog) SetMaxFiles(n int) {
+	fileLog.lock.Lock()
+	fileLog.maxFiles = n
+	fileLog.lock.Unlock()
+}
+
+// SetMaxTotalSize 切分文件的总大小上限(字节)，超过时删除最早的文件，0表示不限制
+func (fileLog *FileLog) SetMaxTotalSize(size int64) {
+	fileLog.lock.Lock()
+	fileLog.maxTotalSize = size
+	fileLog.lock.Unlock()
+}
+
+// rotatedPattern 匹配切分后的文件，如name.2025-03-19.001.log.wf.gz，后缀由FileRoute决定，
+// 不会匹配正在写入的文件
+func rotatedPattern(filename string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(filename) + `\.\d{4}-\d{2}-\d{2}(\.\d+)?\..+$`)
+}
+
+// rotatedExists 切分文件名或其压缩文件已存在
+func rotatedExists(name string) bool {
+	if fileExist(name) {
+		return true
+	}
+
+	for _, ext := range compressedExts {
+		if fileExist(name + ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerCleanup 通知后台协程压缩及清理，多次通知会合并
+func (fileLog *FileLog) triggerCleanup() {
+	select {
+	case fileLog.cleanupCh <- struct{}{}:
+	default:
+	}
+}
+
+// cleanupLoop 压缩及清理都在同一个协程中串行执行，切分只负责重命名，不会与压缩、删除同时操作同一个文件
+func (fileLog *FileLog) cleanupLoop() {
+	for {
+		select {
+		case <-fileLog.cleanupCh:
+		case <-fileLog.closed:
+			return
+		}
+
+		select {
+		case <-fileLog.closed:
+			return
+		default:
+		}
+
+		if err := fileLog.cleanup(); err != nil {
+			fmt.Fprintf(os.Stderr, "FileLogCleanup(%q): %s\n", fileLog.filename, err)
+		}
+	}
+}
+
+type rotatedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// cleanup 压缩未压缩的切分文件，再按天数、文件数及总大小删除最早的文件
+func (fileLog *FileLog) cleanup() error {
+	fileLog.cleanupLock.Lock()
+	defer fileLog.cleanupLock.Unlock()
+
+	fileLog.lock.Lock()
+	compression := fileLog.compression
+	maxDays := fileLog.maxDays
+	maxFiles := fileLog.maxFiles
+	maxTotalSize := fileLog.maxTotalSize
+	fileLog.lock.Unlock()
+
+	files, err := fileLog.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if compression != CompressNone {
+		for i, f := range files {
+			if isCompressed(f.path) {
+				continue
+			}
+
+			select {
+			case <-fileLog.closed:
+				return errors.Join(errs...)
+			default:
+			}
+
+			compressed, err := compressFile(f.path, compression)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			files[i] = compressed
+		}
+	}
+
+	// 从旧到新排序，依次判断是否需要删除
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	deadline := time.Now().Add(-time.Duration(maxDays) * 24 * time.Hour)
+	count := len(files)
+	for _, f := range files {
+		expired := maxDays > 0 && f.modTime.Before(deadline)
+		tooMany := maxFiles > 0 && count > maxFiles
+		tooLarge := maxTotalSize > 0 && total > maxTotalSize
+		if !expired && !tooMany && !tooLarge {
+			continue
+		}
+
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		count--
+		total -= f.size
+	}
+
+	return errors.Join(errs...)
+}
+
+// rotatedFiles 返回日志目录下本日志的切分文件，并删除上次未完成压缩的临时文件
+func (fileLog *FileLog) rotatedFiles() ([]rotatedFile, error) {
+	entries, err := os.ReadDir(fileLog.filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := rotatedPattern(fileLog.filename)
+
+	var files []rotatedFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		path := filepath.Join(fileLog.filepath, name)
+		if strings.HasSuffix(name, ".tmp") && pattern.MatchString(strings.TrimSuffix(name, ".tmp")) {
+			os.Remove(path)
+			continue
+		}
+		if !pattern.MatchString(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	return files, nil
+}
+
+func isCompressed(path string) bool {
+	for _, ext := range compressedExts {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressFile 先写入临时文件再重命名，完成后删除原文件
+func compressFile(path string, compression Compression) (rotatedFile, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return rotatedFile{}, err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return rotatedFile{}, err
+	}
+
+	dstPath := path + compression.ext()
+	tmpPath := dstPath + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return rotatedFile{}, err
+	}
+
+	err = writeCompressed(dst, src, compression)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tmpPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dstPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return rotatedFile{}, NewError("compress %s failed, err:%v", path, err)
+	}
+
+	os.Remove(path)
+
+	compressed, err := os.Stat(dstPath)
+	if err != nil {
+		return rotatedFile{}, err
+	}
+	return rotatedFile{path: dstPath, size: compressed.Size(), modTime: compressed.ModTime()}, nil
+}
+
+func writeCompressed(dst io.Writer, src io.Reader, compression Compression) error {
+	var w io.WriteCloser
+	switch compression {
+	case CompressGzip:
+		w = gzip.NewWriter(dst)
+	case CompressZstd:
+		enc, err := zstd.NewWriter(dst)
+		if err != nil {
+			return err
+		}
+		w = enc
+	default:
+		return NewError("unknown compression %d", compression)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,222 @@
+package tclog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestFileLogRetention(t *testing.T) {
+	type file struct {
+		name string
+		size int
+		age  time.Duration
+	}
+
+	files := []file{
+		{name: "app.2025-03-01.001.log", size: 100, age: 10 * 24 * time.Hour},
+		{name: "app.2025-03-05.001.log.wf.gz", size: 100, age: 6 * 24 * time.Hour},
+		{name: "app.2025-03-06.log", size: 100, age: 5 * 24 * time.Hour},
+		{name: "app.2025-03-09.001.log.zst", size: 100, age: 2 * 24 * time.Hour},
+		{name: "app.2025-03-10.002.log", size: 100, age: time.Hour},
+		{name: "app.log", size: 100, age: 30 * 24 * time.Hour},
+		{name: "app.log.wf", size: 100, age: 30 * 24 * time.Hour},
+		{name: "other.2025-03-01.001.log", size: 100, age: 30 * 24 * time.Hour},
+	}
+
+	tests := []struct {
+		name         string
+		maxDays      int64
+		maxFiles     int
+		maxTotalSize int64
+		remain       []string
+	}{
+		{
+			name:    "max days",
+			maxDays: 7,
+			remain:  []string{"app.2025-03-05.001.log.wf.gz", "app.2025-03-06.log", "app.2025-03-09.001.log.zst", "app.2025-03-10.002.log"},
+		},
+		{
+			name:     "max files",
+			maxFiles: 2,
+			remain:   []string{"app.2025-03-09.001.log.zst", "app.2025-03-10.002.log"},
+		},
+		{
+			name:         "max total size",
+			maxTotalSize: 400,
+			remain:       []string{"app.2025-03-05.001.log.wf.gz", "app.2025-03-06.log", "app.2025-03-09.001.log.zst", "app.2025-03-10.002.log"},
+		},
+		{
+			name:         "combined",
+			maxDays:      3,
+			maxFiles:     3,
+			maxTotalSize: 150,
+			remain:       []string{"app.2025-03-10.002.log"},
+		},
+		{
+			name: "unlimited",
+			remain: []string{"app.2025-03-01.001.log", "app.2025-03-05.001.log.wf.gz", "app.2025-03-06.log",
+				"app.2025-03-09.001.log.zst", "app.2025-03-10.002.log"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			for _, f := range files {
+				path := filepath.Join(dir, f.name)
+				if err := os.WriteFile(path, bytes.Repeat([]byte("x"), f.size), 0644); err != nil {
+					t.Fatalf("write %s failed, %v", path, err)
+				}
+				os.Chtimes(path, now.Add(-f.age), now.Add(-f.age))
+			}
+
+			fileLog := newFileLog()
+			fileLog.filepath = dir
+			fileLog.filename = "app"
+			fileLog.SetMaxDays(tt.maxDays)
+			fileLog.SetMaxFiles(tt.maxFiles)
+			fileLog.SetMaxTotalSize(tt.maxTotalSize)
+
+			if err := fileLog.cleanup(); err != nil {
+				t.Fatalf("cleanup failed, %v", err)
+			}
+
+			remain, _ := fileLog.rotatedFiles()
+			names := make([]string, 0, len(remain))
+			for _, f := range remain {
+				names = append(names, filepath.Base(f.path))
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.remain, ",") {
+				t.Fatalf("expected %v, got %v", tt.remain, names)
+			}
+
+			for _, name := range []string{"app.log", "app.log.wf", "other.2025-03-01.001.log"} {
+				if !fileExist(filepath.Join(dir, name)) {
+					t.Fatalf("%s should not be removed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestFileLogCompression(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression Compression
+		ext         string
+		decompress  func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			name:        "gzip",
+			compression: CompressGzip,
+			ext:         ".gz",
+			decompress:  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		},
+		{
+			name:        "zstd",
+			compression: CompressZstd,
+			ext:         ".zst",
+			decompress: func(r io.Reader) (io.Reader, error) {
+				dec, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return dec.IOReadCloser(), nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := strings.Repeat("compress me\n", 100)
+			rotated := filepath.Join(dir, "app.2025-03-01.001.log.wf")
+			if err := os.WriteFile(rotated, []byte(content), 0644); err != nil {
+				t.Fatalf("write failed, %v", err)
+			}
+			modTime := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+			os.Chtimes(rotated, modTime, modTime)
+			// 上次压缩中断留下的临时文件
+			os.WriteFile(rotated+tt.ext+".tmp", []byte("partial"), 0644)
+
+			fileLog := newFileLog()
+			fileLog.filepath = dir
+			fileLog.filename = "app"
+			fileLog.SetCompression(tt.compression)
+
+			if err := fileLog.cleanup(); err != nil {
+				t.Fatalf("cleanup failed, %v", err)
+			}
+
+			if fileExist(rotated) || fileExist(rotated+tt.ext+".tmp") {
+				t.Fatalf("source and tmp file should be removed")
+			}
+
+			f, err := os.Open(rotated + tt.ext)
+			if err != nil {
+				t.Fatalf("compressed file missing, %v", err)
+			}
+			defer f.Close()
+
+			info, _ := f.Stat()
+			if !info.ModTime().Equal(modTime) {
+				t.Fatalf("mtime should be kept, expected %v, got %v", modTime, info.ModTime())
+			}
+
+			r, err := tt.decompress(f)
+			if err != nil {
+				t.Fatalf("decompress failed, %v", err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil || string(data) != content {
+				t.Fatalf("unexpected content, err:%v", err)
+			}
+		})
+	}
+}
+
+func TestFileLogRotateNumber(t *testing.T) {
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "num")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+	defer fileLog.Close()
+
+	fileLog.EnableDaily(false)
+	fileLog.SetMaxSize(0)
+	fileLog.SetMaxLines(1)
+
+	// 已压缩的文件占用序号，超过原来的MaxFileNum也能继续切分
+	date := time.Now().In(cstLocal).Format("2006-01-02")
+	for i := 1; i <= MaxFileNum; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("num.%s.%03d.log.gz", date, i))
+		os.WriteFile(name, nil, 0644)
+	}
+
+	for i := 0; i < 3; i++ {
+		e := &Entry{Time: time.Now().In(cstLocal), Level: LevelInfo, Message: "line"}
+		if err := fileLog.WriteEntry(e); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+
+	for _, num := range []string{"1001", "1002"} {
+		name := filepath.Join(dir, "num."+date+"."+num+".log")
+		if !fileExist(name) {
+			t.Fatalf("%s should be rotated", name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package tclog
+
+import (
+	"os"
+	"strings"
+)
+
+// FileRoute 将Levels中级别的日志写入filename+Suffix，同一级别可写入多个文件，未配置的级别不写入文件
+type FileRoute struct {
+	Suffix string   // 文件后缀，如".log"、".log.wf"、".debug.log"
+	Levels []string // 级别名，如"debug"、"warn"
+}
+
+// DefaultFileRoutes 默认Debug、Info、Notice写入.log，Warn及以上写入.log.wf
+func DefaultFileRoutes() []FileRoute {
+	return []FileRoute{
+		{Suffix: ".log", Levels: []string{"debug", "info", "notice"}},
+		{Suffix: ".log.wf", Levels: []string{"warn", "error", "fatal"}},
+	}
+}
+
+// fileOutput 一个输出文件，各自统计行数、大小及打开日期用于切分
+type fileOutput struct {
+	suffix string
+	file   *os.File
+	buf    []byte
+
+	curLines int
+	curSize  int
+	openDate int
+}
+
+func parseLevel(level string) (int, bool) {
+	for i, text := range levelTextArray {
+		if strings.EqualFold(level, text) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetRoutes 修改级别到文件的映射，后缀不变的文件保持打开及切分统计，
+// 不再使用的文件写入缓冲后关闭，文件为空时删除
+func (fileLog *FileLog) SetRoutes(routes ...FileRoute) error {
+	fileLog.lock.Lock()
+	defer fileLog.lock.Unlock()
+
+	return fileLog.setRoutes(routes)
+}
+
+// setRoutes 调用方需持有锁
+func (fileLog *FileLog) setRoutes(routes []FileRoute) error {
+	if len(routes) == 0 {
+		return NewError("no file route")
+	}
+
+	existing := make(map[string]*fileOutput, len(fileLog.outputs))
+	for _, o := range fileLog.outputs {
+		existing[o.suffix] = o
+	}
+
+	var (
+		outputs  []*fileOutput
+		byLevel  [LevelFatal + 1][]*fileOutput
+		suffixes = make(map[string]bool, len(routes))
+	)
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Suffix, ".") || strings.Contains(route.Suffix, "/") {
+			return NewError("invalid file route suffix %q", route.Suffix)
+		}
+		if suffixes[route.Suffix] {
+			return NewError("duplicate file route suffix %q", route.Suffix)
+		}
+		for _, ext := range append([]string{".tmp"}, compressedExts...) {
+			if strings.HasSuffix(route.Suffix, ext) {
+				return NewError("file route suffix %q must not end with %s", route.Suffix, ext)
+			}
+		}
+		suffixes[route.Suffix] = true
+
+		o := existing[route.Suffix]
+		if o == nil {
+			o = &fileOutput{suffix: route.Suffix}
+		}
+		outputs = append(outputs, o)
+
+		for _, level := range route.Levels {
+			l, ok := parseLevel(level)
+			if !ok {
+				return NewError("invalid level %q in file route %q", level, route.Suffix)
+			}
+			byLevel[l] = append(byLevel[l], o)
+		}
+	}
+
+	// 先打开新文件，失败时保持原来的映射
+	var opened []*fileOutput
+	for _, o := range outputs {
+		if o.file != nil {
+			continue
+		}
+		if err := fileLog.openOutput(o); err != nil {
+			for _, o := range opened {
+				o.file.Close()
+				o.file = nil
+			}
+			return err
+		}
+		opened = append(opened, o)
+	}
+
+	for _, o := range fileLog.outputs {
+		if suffixes[o.suffix] {
+			continue
+		}
+		fileLog.closeOutput(o)
+	}
+
+	fileLog.outputs = outputs
+	fileLog.routes = byLevel
+	return nil
+}
+
+// closeOutput 写入缓冲并关闭不再使用的文件，文件为空时删除，调用方需持有锁
+func (fileLog *FileLog) closeOutput(o *fileOutput) {
+	fileLog.flushBuffer(o)
+	if o.file == nil {
+		return
+	}
+
+	info, err := o.file.Stat()
+	o.file.Close()
+	o.file = nil
+	if err == nil && info.Size() == 0 {
+		os.Remove(fileLog.outputPath(o))
+	}
+}
@@ -0,0 +1,173 @@
+package tclog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileLogRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []FileRoute
+		files  map[string][]string // 文件后缀及其中的日志
+	}{
+		{
+			name:   "default",
+			routes: DefaultFileRoutes(),
+			files: map[string][]string{
+				".log":    {"DEBUG", "INFO", "NOTICE"},
+				".log.wf": {"WARN", "ERROR", "FATAL"},
+			},
+		},
+		{
+			name: "separate debug",
+			routes: []FileRoute{
+				{Suffix: ".debug.log", Levels: []string{"debug"}},
+				{Suffix: ".log", Levels: []string{"info", "notice"}},
+				{Suffix: ".log.wf", Levels: []string{"warn", "error", "fatal"}},
+			},
+			files: map[string][]string{
+				".debug.log": {"DEBUG"},
+				".log":       {"INFO", "NOTICE"},
+				".log.wf":    {"WARN", "ERROR", "FATAL"},
+			},
+		},
+		{
+			name:   "combined",
+			routes: []FileRoute{{Suffix: ".log", Levels: []string{"debug", "info", "notice", "warn", "error", "fatal"}}},
+			files: map[string][]string{
+				".log": {"DEBUG", "INFO", "NOTICE", "WARN", "ERROR", "FATAL"},
+			},
+		},
+		{
+			name: "per level and overlapping",
+			routes: []FileRoute{
+				{Suffix: ".info.log", Levels: []string{"INFO"}},
+				{Suffix: ".error.log", Levels: []string{"error"}},
+				{Suffix: ".all.log", Levels: []string{"info", "error"}},
+			},
+			files: map[string][]string{
+				".info.log":  {"INFO"},
+				".error.log": {"ERROR"},
+				".all.log":   {"INFO", "ERROR"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileLog, err := NewFileLogWithRoutes(dir, "route", tt.routes...)
+			if err != nil {
+				t.Fatalf("create file log failed, %v", err)
+			}
+
+			for level := LevelDebug; level <= LevelFatal; level++ {
+				e := &Entry{Time: time.Now().In(cstLocal), Level: level, Message: levelTextArray[level]}
+				if err := fileLog.WriteEntry(e); err != nil {
+					t.Fatalf("write failed, %v", err)
+				}
+			}
+			fileLog.Close()
+
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.files) {
+				t.Fatalf("expected %d files, got %d", len(tt.files), len(entries))
+			}
+
+			for suffix, msgs := range tt.files {
+				data, err := os.ReadFile(filepath.Join(dir, "route"+suffix))
+				if err != nil {
+					t.Fatalf("read %s failed, %v", suffix, err)
+				}
+				lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+				if len(lines) != len(msgs) {
+					t.Fatalf("%s: expected %v, got %q", suffix, msgs, lines)
+				}
+				for i := range lines {
+					if !strings.HasSuffix(lines[i], " "+msgs[i]) {
+						t.Fatalf("%s: expected line %d ending with %q, got %q", suffix, i, msgs[i], lines[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFileLogRoutesInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes []FileRoute
+	}{
+		{name: "empty"},
+		{name: "no dot", routes: []FileRoute{{Suffix: "log", Levels: []string{"info"}}}},
+		{name: "path", routes: []FileRoute{{Suffix: ".a/b.log", Levels: []string{"info"}}}},
+		{name: "compressed ext", routes: []FileRoute{{Suffix: ".log.gz", Levels: []string{"info"}}}},
+		{name: "duplicate", routes: []FileRoute{{Suffix: ".log"}, {Suffix: ".log"}}},
+		{name: "unknown level", routes: []FileRoute{{Suffix: ".log", Levels: []string{"trace"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFileLogWithRoutes(t.TempDir(), "invalid", tt.routes...); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestFileLogSetRoutes(t *testing.T) {
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "switch")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+	defer fileLog.Close()
+
+	fileLog.SetMaxLines(3)
+	write := func(level int) {
+		if err := fileLog.WriteEntry(&Entry{Time: time.Now().In(cstLocal), Level: level, Message: "m"}); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+	}
+
+	write(LevelInfo)
+	write(LevelInfo)
+	normal := fileLog.outputs[0]
+
+	// .log保持打开及行数统计，空的.log.wf被删除
+	if err := fileLog.SetRoutes(
+		FileRoute{Suffix: ".log", Levels: []string{"info", "warn"}},
+		FileRoute{Suffix: ".debug.log", Levels: []string{"debug"}},
+	); err != nil {
+		t.Fatalf("set routes failed, %v", err)
+	}
+	if fileLog.outputs[0] != normal || normal.curLines != 2 {
+		t.Fatalf("unchanged output should be kept, lines %d", normal.curLines)
+	}
+	if fileExist(filepath.Join(dir, "switch.log.wf")) {
+		t.Fatalf("empty unused file should be removed")
+	}
+
+	write(LevelWarn)
+	write(LevelInfo)
+	write(LevelDebug)
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "switch.*.001.log"))
+	if len(rotated) != 1 || fileSize(t, rotated[0]) == 0 {
+		t.Fatalf("expected .log rotated once, got %v", rotated)
+	}
+	if normal.curLines != 1 || fileLog.outputs[1].curLines != 1 {
+		t.Fatalf("unexpected lines %d, %d", normal.curLines, fileLog.outputs[1].curLines)
+	}
+
+	if err := fileLog.SetRoutes(FileRoute{Suffix: "bad"}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if len(fileLog.outputs) != 2 || fileLog.outputs[1].file == nil {
+		t.Fatalf("routes should be kept on error")
+	}
+}
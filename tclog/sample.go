@@ -0,0 +1,199 @@
+package tclog
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSampleInterval       = time.Second
+	DefaultSampleReportInterval = 10 * time.Second
+)
+
+// SampleKey 采样时区分日志位置的方式
+type SampleKey int
+
+const (
+	SampleByFormat SampleKey = iota // 默认，按级别+格式字符串
+	SampleByCaller                  // 按调用位置
+)
+
+// SampleOptions 一个级别的采样配置，每个位置每个Interval内先输出前First条，之后每Thereafter条输出一条
+type SampleOptions struct {
+	Interval       time.Duration // 统计周期，默认1s
+	First          int
+	Thereafter     int           // 0表示超过First后不再输出
+	Key            SampleKey     // 区分位置的方式，默认按格式字符串
+	ReportInterval time.Duration // 每隔多久为每个位置输出一条"N messages suppressed by sampling"，默认10s
+}
+
+// sampler 一个级别的采样状态
+type sampler struct {
+	opts SampleOptions
+	stop chan struct{}
+
+	lock  sync.Mutex
+	sites map[any]*sampleSite
+}
+
+type sampleSite struct {
+	start      time.Time
+	count      int
+	suppressed uint64
+	active     bool // 上次输出汇总后是否有日志，没有的位置在汇总时删除
+}
+
+// SetSampling 对level级别的日志采样，With返回的子日志共用采样状态，
+// opts的First及Thereafter都为0时关闭采样，Output写入的日志不采样
+func (log *TcLog) SetSampling(level string, opts SampleOptions) {
+	l := log.levelFromStr(level)
+
+	var s *sampler
+	if opts.First > 0 || opts.Thereafter > 0 {
+		if opts.Interval <= 0 {
+			opts.Interval = DefaultSampleInterval
+		}
+		if opts.ReportInterval <= 0 {
+			opts.ReportInterval = DefaultSampleReportInterval
+		}
+		s = &sampler{
+			opts:  opts,
+			stop:  make(chan struct{}),
+			sites: make(map[any]*sampleSite),
+		}
+	}
+
+	if s != nil {
+		go log.sampleLoop(l, s)
+	}
+
+	if old := log.samplers[l].Swap(s); old != nil {
+		close(old.stop)
+	}
+}
+
+// sampled 返回该日志是否被采样丢弃，depth与write相同
+func (log *TcLog) sampled(level, depth int, format string) bool {
+	s := log.samplers[level].Load()
+	if s == nil {
+		return false
+	}
+
+	var key any = format
+	if s.opts.Key == SampleByCaller {
+		var pcs [1]uintptr
+		runtime.Callers(depth+1, pcs[:])
+		key = pcs[0]
+	}
+
+	now := time.Now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	site := s.sites[key]
+	if site == nil {
+		site = &sampleSite{start: now}
+		s.sites[key] = site
+	}
+	site.active = true
+
+	if now.Sub(site.start) >= s.opts.Interval {
+		site.start = now
+		site.count = 0
+	}
+	site.count++
+
+	if site.count <= s.opts.First {
+		return false
+	}
+	if s.opts.Thereafter > 0 && (site.count-s.opts.First)%s.opts.Thereafter == 0 {
+		return false
+	}
+
+	site.suppressed++
+	return true
+}
+
+// sampleLoop 定时输出各位置被丢弃的日志数，采样配置被替换或日志关闭时退出
+func (log *TcLog) sampleLoop(level int, s *sampler) {
+	ticker := time.NewTicker(s.opts.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			log.putEntries(log.takeSampled(level, s))
+			return
+		case <-log.done:
+			return
+		}
+
+		log.putEntries(log.takeSampled(level, s))
+	}
+}
+
+func (log *TcLog) putEntries(entries []*Entry) {
+	for _, e := range entries {
+		if !log.queue.put(e) {
+			releaseEntry(e)
+		}
+	}
+}
+
+// reportSampled 关闭时直接写入各输出，不经过队列
+func (log *TcLog) reportSampled() {
+	for level := range log.samplers {
+		if s := log.samplers[level].Load(); s != nil {
+			for _, e := range log.takeSampled(level, s) {
+				log.output(e)
+			}
+		}
+	}
+}
+
+// takeSampled 为每个有日志被丢弃的位置生成一条同级别的汇总日志，并删除不再活跃的位置
+func (log *TcLog) takeSampled(level int, s *sampler) []*Entry {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var entries []*Entry
+	for key, site := range s.sites {
+		if !site.active {
+			delete(s.sites, key)
+			continue
+		}
+		site.active = false
+
+		if site.suppressed == 0 {
+			continue
+		}
+
+		e := entryPool.Get().(*Entry)
+		e.Time = time.Now().In(cstLocal)
+		e.Level = level
+		e.Host = log.hostname
+		e.Message = "tclog: " + strconv.FormatUint(site.suppressed, 10) + " messages suppressed by sampling"
+		e.Caller = Caller{}
+		e.Stack = ""
+		e.Fields = append(e.Fields[:0], String("site", sampleSiteName(key)), Uint64("suppressed", site.suppressed))
+		entries = append(entries, e)
+
+		site.suppressed = 0
+	}
+
+	return entries
+}
+
+func sampleSiteName(key any) string {
+	pc, ok := key.(uintptr)
+	if !ok {
+		return key.(string)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return frame.File + ":" + strconv.Itoa(frame.Line)
+}
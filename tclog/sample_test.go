@@ -0,0 +1,180 @@
+package tclog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink 记录写入的日志及字段
+type recordSink struct {
+	lock    sync.Mutex
+	entries []Entry
+}
+
+func (s *recordSink) WriteEntry(e *Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c := *e
+	c.Fields = append([]Field(nil), e.Fields...)
+	s.entries = append(s.entries, c)
+	return nil
+}
+
+func (s *recordSink) Flush() error { return nil }
+func (s *recordSink) Close() error { return nil }
+
+func (s *recordSink) split() (msgs []string, summaries []Entry) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, e := range s.entries {
+		if strings.HasPrefix(e.Message, "tclog: ") {
+			summaries = append(summaries, e)
+			continue
+		}
+		msgs = append(msgs, e.Message)
+	}
+	return msgs, summaries
+}
+
+func TestSampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       SampleOptions
+		log        func(logger *TcLog)
+		logged     int
+		suppressed map[string]uint64 // site及被丢弃的日志数
+	}{
+		{
+			name: "first then every mth",
+			opts: SampleOptions{First: 3, Thereafter: 5},
+			log: func(logger *TcLog) {
+				for i := 0; i < 20; i++ {
+					logger.Error("db timeout %d", i)
+				}
+			},
+			logged:     6,
+			suppressed: map[string]uint64{"db timeout %d": 14},
+		},
+		{
+			name: "first only",
+			opts: SampleOptions{First: 2},
+			log: func(logger *TcLog) {
+				for i := 0; i < 10; i++ {
+					logger.Error("db timeout %d", i)
+					logger.Error("cache miss")
+				}
+			},
+			logged:     4,
+			suppressed: map[string]uint64{"db timeout %d": 8, "cache miss": 8},
+		},
+		{
+			name: "interval reset",
+			opts: SampleOptions{First: 2, Interval: 20 * time.Millisecond},
+			log: func(logger *TcLog) {
+				for i := 0; i < 3; i++ {
+					logger.Error("burst")
+				}
+				time.Sleep(30 * time.Millisecond)
+				for i := 0; i < 3; i++ {
+					logger.Error("burst")
+				}
+			},
+			logged:     4,
+			suppressed: map[string]uint64{"burst": 2},
+		},
+		{
+			name: "by caller",
+			opts: SampleOptions{First: 1, Key: SampleByCaller},
+			log: func(logger *TcLog) {
+				for _, format := range []string{"a", "b", "c"} {
+					logger.Error(format)
+				}
+				logger.With(String("k", "v")).Error("d")
+			},
+			logged:     2,
+			suppressed: map[string]uint64{"sample_test.go:": 2},
+		},
+		{
+			name: "other level not sampled",
+			opts: SampleOptions{First: 1},
+			log: func(logger *TcLog) {
+				for i := 0; i < 5; i++ {
+					logger.Warn("warn")
+				}
+			},
+			logged: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordSink{}
+			logger := NewTcLogWithSinks("debug", SinkConfig{Sink: sink})
+			logger.SetSampling("error", tt.opts)
+
+			tt.log(logger)
+			logger.Close()
+
+			msgs, summaries := sink.split()
+			if len(msgs) != tt.logged {
+				t.Fatalf("expected %d logged, got %q", tt.logged, msgs)
+			}
+			if len(summaries) != len(tt.suppressed) {
+				t.Fatalf("expected %d summaries, got %v", len(tt.suppressed), summaries)
+			}
+
+			for _, e := range summaries {
+				site, n := e.Fields[0].Value().(string), e.Fields[1].Value().(uint64)
+				found := false
+				for prefix, expected := range tt.suppressed {
+					if strings.Contains(site, prefix) {
+						found = n == expected && e.Level == LevelError
+					}
+				}
+				if !found {
+					t.Fatalf("unexpected summary %s %v", e.Message, e.Fields)
+				}
+			}
+		})
+	}
+}
+
+func TestSamplingReport(t *testing.T) {
+	sink := &recordSink{}
+	logger := NewTcLogWithSinks("debug", SinkConfig{Sink: sink})
+	defer logger.Close()
+
+	logger.SetSampling("info", SampleOptions{First: 1, ReportInterval: 20 * time.Millisecond})
+	for i := 0; i < 5; i++ {
+		logger.Info("hot path")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, summaries := sink.split(); len(summaries) == 1 {
+			if summaries[0].Message != "tclog: 4 messages suppressed by sampling" {
+				t.Fatalf("unexpected summary %q", summaries[0].Message)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("summary should be reported periodically")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 关闭采样后全部输出
+	logger.SetSampling("info", SampleOptions{})
+	for i := 0; i < 3; i++ {
+		logger.Info("hot path")
+	}
+	logger.Flush()
+
+	if msgs, summaries := sink.split(); len(msgs) != 4 || len(summaries) != 1 {
+		t.Fatalf("unexpected messages %q, summaries %d", msgs, len(summaries))
+	}
+}
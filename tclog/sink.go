@@ -0,0 +1,155 @@
+package tclog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink 日志的输出，WriteEntry由日志的写入协程串行调用，编码格式由Sink自己决定
+type Sink interface {
+	WriteEntry(e *Entry) error
+	Flush() error
+	Close() error
+}
+
+// SinkConfig 输出及其最低日志级别，Level为空时输出所有通过TcLog级别过滤的日志
+type SinkConfig struct {
+	Sink  Sink
+	Level string
+}
+
+type sinkEntry struct {
+	sink  Sink
+	level int
+}
+
+// AddSink 增加日志输出，level为该输出的最低级别，为空时不额外过滤
+func (log *TcLog) AddSink(sink Sink, level string) {
+	minLevel := LevelDebug
+	if level != "" {
+		minLevel = log.levelFromStr(level)
+	}
+
+	log.sinksLock.Lock()
+	log.sinks = append(log.sinks, sinkEntry{sink: sink, level: minLevel})
+	log.sinksLock.Unlock()
+}
+
+// output 将日志写入所有级别满足的输出，写入失败时输出到stderr
+func (log *TcLog) output(e *Entry) {
+	log.sinksLock.RLock()
+	for _, s := range log.sinks {
+		if e.Level < s.level {
+			continue
+		}
+		if err := s.sink.WriteEntry(e); err != nil {
+			fmt.Fprintf(os.Stderr, "tclog: write %T failed, %v\n", s.sink, err)
+		}
+	}
+	log.sinksLock.RUnlock()
+
+	releaseEntry(e)
+}
+
+func releaseEntry(e *Entry) {
+	e.Fields = e.Fields[:0]
+	entryPool.Put(e)
+}
+
+func (log *TcLog) flushSinks() {
+	log.sinksLock.RLock()
+	defer log.sinksLock.RUnlock()
+
+	for _, s := range log.sinks {
+		if err := s.sink.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "tclog: flush %T failed, %v\n", s.sink, err)
+		}
+	}
+}
+
+func (log *TcLog) closeSinks() {
+	log.sinksLock.RLock()
+	defer log.sinksLock.RUnlock()
+
+	for _, s := range log.sinks {
+		if err := s.sink.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "tclog: close %T failed, %v\n", s.sink, err)
+		}
+	}
+}
+
+// WriterSink 写入io.Writer，每条日志调用一次Write
+type WriterSink struct {
+	lock    sync.Mutex
+	w       io.Writer
+	encoder Encoder
+	noClose bool
+	buf     []byte
+}
+
+// NewWriterSink enc为nil时使用文本格式，Close时若w实现了io.Closer则关闭w
+func NewWriterSink(w io.Writer, enc Encoder) *WriterSink {
+	if enc == nil {
+		enc = NewTextEncoder(EncoderConfig{})
+	}
+
+	return &WriterSink{
+		w:       w,
+		encoder: enc,
+	}
+}
+
+// NewStdoutSink 写入标准输出，Close时不关闭
+func NewStdoutSink(enc Encoder) *WriterSink {
+	sink := NewWriterSink(os.Stdout, enc)
+	sink.noClose = true
+	return sink
+}
+
+// NewStderrSink 写入标准错误，Close时不关闭
+func NewStderrSink(enc Encoder) *WriterSink {
+	sink := NewWriterSink(os.Stderr, enc)
+	sink.noClose = true
+	return sink
+}
+
+func (sink *WriterSink) WriteEntry(e *Entry) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	sink.buf = sink.encoder.Encode(sink.buf[:0], e)
+	_, err := sink.w.Write(sink.buf)
+	return err
+}
+
+// Flush w实现了Sync() error或Flush() error时调用
+func (sink *WriterSink) Flush() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	switch w := sink.w.(type) {
+	case interface{ Sync() error }:
+		if sink.noClose {
+			// 标准输出为终端或管道时Sync会返回错误，忽略
+			w.Sync()
+			return nil
+		}
+		return w.Sync()
+	case interface{ Flush() error }:
+		return w.Flush()
+	}
+	return nil
+}
+
+func (sink *WriterSink) Close() error {
+	if sink.noClose {
+		return nil
+	}
+
+	if c, ok := sink.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
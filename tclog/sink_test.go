@@ -0,0 +1,144 @@
+package tclog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSinks(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp failed, %v", err)
+	}
+	defer udp.Close()
+
+	syslog, err := NewSyslogSink(SyslogConfig{Addr: udp.LocalAddr().String(), AppName: "tclog test", Facility: 16})
+	if err != nil {
+		t.Fatalf("create syslog sink failed, %v", err)
+	}
+
+	dir := t.TempDir()
+	fileLog, err := NewFileLog(dir, "sink")
+	if err != nil {
+		t.Fatalf("create file log failed, %v", err)
+	}
+
+	var buf bytes.Buffer
+	logger := NewTcLogWithSinks("info",
+		SinkConfig{Sink: fileLog},
+		SinkConfig{Sink: NewWriterSink(&buf, NewLogfmtEncoder(EncoderConfig{TimeFormat: TimeFormatUnix})), Level: "notice"},
+		SinkConfig{Sink: syslog, Level: "warn"},
+	)
+	reqLog := logger.With(String("request_id", "r-1"))
+
+	reqLog.Debug("filtered by logger")
+	reqLog.Info("to file only")
+	reqLog.Notice("to file and writer")
+	reqLog.Error("to all", Err(os.ErrNotExist))
+	logger.Close()
+
+	data, _ := os.ReadFile(filepath.Join(dir, "sink.log"))
+	wf, _ := os.ReadFile(filepath.Join(dir, "sink.log.wf"))
+	if n := strings.Count(string(data), "\n"); n != 2 || !strings.Contains(string(wf), "to all") {
+		t.Fatalf("unexpected file content %q %q", data, wf)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `level=NOTICE`) || !strings.HasSuffix(lines[1], `msg="to all" request_id=r-1 error="file does not exist"`) {
+		t.Fatalf("unexpected writer content %q", lines)
+	}
+
+	udp.SetReadDeadline(time.Now().Add(time.Second))
+	packet := make([]byte, 2048)
+	n, _, err := udp.ReadFrom(packet)
+	if err != nil {
+		t.Fatalf("read syslog failed, %v", err)
+	}
+
+	pattern := regexp.MustCompile(`^<131>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}\+08:00 \S+ tclogtest ` +
+		strconv.Itoa(os.Getpid()) + ` - - to all request_id=r-1 error="file does not exist"$`)
+	if !pattern.Match(packet[:n]) {
+		t.Fatalf("unexpected syslog message %q", packet[:n])
+	}
+
+	udp.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, _, err := udp.ReadFrom(packet); err == nil {
+		t.Fatalf("only warn and above should be sent to syslog")
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen tcp failed, %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	sink, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), AppName: "app", Hostname: "h1"})
+	if err != nil {
+		t.Fatalf("create syslog sink failed, %v", err)
+	}
+	defer sink.Close()
+
+	when := time.Date(2025, 3, 19, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		entry  Entry
+		expect string
+	}{
+		{
+			entry:  Entry{Time: when, Level: LevelInfo, Message: "first", Fields: []Field{Int("n", 1)}},
+			expect: "<14>1 2025-03-19T10:00:00.000000Z h1 app " + strconv.Itoa(os.Getpid()) + " - - first n=1",
+		},
+		{
+			entry:  Entry{Time: when, Level: LevelFatal, Message: "multi\nline"},
+			expect: "<10>1 2025-03-19T10:00:00.000000Z h1 app " + strconv.Itoa(os.Getpid()) + " - - multi\nline",
+		},
+	}
+
+	for _, tt := range tests {
+		if err := sink.WriteEntry(&tt.entry); err != nil {
+			t.Fatalf("write failed, %v", err)
+		}
+
+		select {
+		case msg := <-received:
+			if msg != tt.expect {
+				t.Fatalf("expected %q, got %q", tt.expect, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %q not received", tt.expect)
+		}
+	}
+}
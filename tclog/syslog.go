@@ -0,0 +1,213 @@
+package tclog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyslogFacility user-level messages
+	DefaultSyslogFacility = 1
+	DefaultSyslogTimeout  = 3 * time.Second
+
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// 日志级别对应的syslog severity
+var syslogSeverity = []int{
+	LevelDebug:  7,
+	LevelInfo:   6,
+	LevelNotice: 5,
+	LevelWarn:   4,
+	LevelError:  3,
+	LevelFatal:  2,
+}
+
+// SyslogConfig RFC 5424格式的syslog输出配置
+type SyslogConfig struct {
+	Network  string        // udp、tcp、unix(依次尝试unixgram和unix)，默认udp
+	Addr     string        // 如127.0.0.1:514、/dev/log
+	Facility int           // 默认1(user)
+	AppName  string        // 默认为进程名
+	Hostname string        // 默认使用日志的主机名
+	Encoder  Encoder       // MSG部分的编码格式，默认为"消息 key=value"，时间、级别已在头部中
+	Timeout  time.Duration // 连接及写入超时，默认3s
+}
+
+// SyslogSink 写入syslog，UDP及unixgram每条日志一个报文，TCP及unix按RFC 6587使用长度前缀分帧，
+// 写入失败时重新连接并重试一次
+type SyslogSink struct {
+	cfg   SyslogConfig
+	pid   string
+	frame bool
+
+	lock sync.Mutex
+	conn net.Conn
+	buf  []byte
+	msg  []byte
+}
+
+func NewSyslogSink(cfg SyslogConfig) (*SyslogSink, error) {
+	if cfg.Network == "" {
+		cfg.Network = "udp"
+	}
+
+	if cfg.Facility <= 0 {
+		cfg.Facility = DefaultSyslogFacility
+	}
+
+	if cfg.AppName == "" {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+
+	if cfg.Encoder == nil {
+		cfg.Encoder = messageEncoder{}
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultSyslogTimeout
+	}
+
+	sink := &SyslogSink{
+		cfg: cfg,
+		pid: strconv.Itoa(os.Getpid()),
+	}
+
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if err := sink.connect(); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (sink *SyslogSink) connect() error {
+	networks := []string{sink.cfg.Network}
+	if sink.cfg.Network == "unix" {
+		networks = []string{"unixgram", "unix"}
+	}
+
+	var err error
+	for _, network := range networks {
+		var conn net.Conn
+		conn, err = net.DialTimeout(network, sink.cfg.Addr, sink.cfg.Timeout)
+		if err == nil {
+			sink.conn = conn
+			sink.frame = network == "tcp" || network == "tcp4" || network == "tcp6" || network == "unix"
+			return nil
+		}
+	}
+
+	return NewError("dial syslog %s %s failed, err:%v", sink.cfg.Network, sink.cfg.Addr, err)
+}
+
+func (sink *SyslogSink) WriteEntry(e *Entry) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	sink.msg = sink.cfg.Encoder.Encode(sink.msg[:0], e)
+	sink.msg = bytes.TrimRight(sink.msg, "\n")
+
+	err := sink.write(e)
+	if err == nil {
+		return nil
+	}
+
+	// 连接断开时重新连接并重试一次
+	if sink.conn != nil {
+		sink.conn.Close()
+		sink.conn = nil
+	}
+	if err := sink.connect(); err != nil {
+		return err
+	}
+	return sink.write(e)
+}
+
+func (sink *SyslogSink) write(e *Entry) error {
+	if sink.conn == nil {
+		return NewError("syslog not connected")
+	}
+
+	sink.buf = sink.appendMessage(sink.buf[:0], e)
+
+	sink.conn.SetWriteDeadline(time.Now().Add(sink.cfg.Timeout))
+	_, err := sink.conn.Write(sink.buf)
+	return err
+}
+
+// appendMessage <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func (sink *SyslogSink) appendMessage(buf []byte, e *Entry) []byte {
+	hostname := sink.cfg.Hostname
+	if hostname == "" {
+		hostname = e.Host
+	}
+
+	header := fmt.Appendf(nil, "<%d>1 %s %s %s %s - - ",
+		sink.cfg.Facility*8+syslogSeverity[e.Level],
+		e.Time.Format(syslogTimeFormat),
+		syslogHeaderValue(hostname, 255),
+		syslogHeaderValue(sink.cfg.AppName, 48),
+		sink.pid,
+	)
+
+	if sink.frame {
+		buf = strconv.AppendInt(buf, int64(len(header)+len(sink.msg)), 10)
+		buf = append(buf, ' ')
+	}
+
+	buf = append(buf, header...)
+	return append(buf, sink.msg...)
+}
+
+// syslogHeaderValue 头部字段只能是可打印的ASCII字符，为空时使用"-"
+func syslogHeaderValue(s string, maxLen int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < maxLen; i++ {
+		if s[i] > ' ' && s[i] < 0x7f {
+			b = append(b, s[i])
+		}
+	}
+
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+func (sink *SyslogSink) Flush() error {
+	return nil
+}
+
+func (sink *SyslogSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	if sink.conn == nil {
+		return nil
+	}
+
+	err := sink.conn.Close()
+	sink.conn = nil
+	return err
+}
+
+// messageEncoder 只输出调用位置、消息及字段，用于syslog等头部已包含时间和级别的输出
+type messageEncoder struct{}
+
+func (messageEncoder) Encode(buf []byte, e *Entry) []byte {
+	if e.Caller.Defined {
+		buf = fmt.Appendf(buf, "func:%s file:%s line:%d ", e.Caller.Function, e.Caller.File, e.Caller.Line)
+	}
+
+	buf = append(buf, e.Message...)
+	return appendFields(buf, e.Fields)
+}
@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,7 +30,11 @@ const (
 	FileDefMaxLines   = 1000000
 	FileDefMaxSize    = 1 << 28 //256 MB
 	FileDefMaxDays    = 7
-	MaxFileNum        = 1000
+	// Deprecated: 切分序号不再有上限，保留兼容
+	MaxFileNum = 1000
+
+	DefaultFileBufferSize = 256 << 10 // 256 KB
+	DefaultFlushInterval  = time.Second
 )
 
 var (
@@ -43,68 +48,90 @@ var (
 	}
 )
 
+// TcLog 日志，With返回的子日志与父日志共用级别、队列及文件
 type TcLog struct {
-	level               int
+	*core
+	fields []Field
+}
+
+type core struct {
+	level               atomic.Int32 // 支持运行时修改
 	enableFuncCallDepth bool
 	logFuncCallDepth    int
-	msgChan             chan *logMsg
-	signalChan          chan string
+	stackLevel          atomic.Int32
+	queue               *queue
+	done                chan struct{}
 	hostname            string
-	fileLog             *FileLog
-}
+	fileLog             *FileLog // NewTcLog创建的日志文件，SetMaxDays等设置作用于该文件
 
-type logMsg struct {
-	level int
-	msg   string
-	when  time.Time
+	sinksLock sync.RWMutex
+	sinks     []sinkEntry
+
+	samplers [LevelFatal + 1]atomic.Pointer[sampler] // 各级别的采样，nil表示不采样
 }
 
-var logMsgPool *sync.Pool
-var cstLocal *time.Location
+var entryPool = &sync.Pool{
+	New: func() interface{} {
+		return &Entry{}
+	},
+}
 
-func NewTcLog(filepath, filename, level string) (*TcLog, error) {
+var cstLocal = loadCstLocal()
 
-	log := &TcLog{
-		level:               LevelDebug,
-		enableFuncCallDepth: false,
-		logFuncCallDepth:    TcLogDefCallDepth,
-		msgChan:             make(chan *logMsg, 100),
-		signalChan:          make(chan string, 1),
-		fileLog:             newFileLog(),
+func loadCstLocal() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*3600)
 	}
+	return loc
+}
 
-	logMsgPool = &sync.Pool{
-		New: func() interface{} {
-			return &logMsg{}
-		},
+func NewTcLog(filepath, filename, level string) (*TcLog, error) {
+	fileLog, err := NewFileLog(filepath, filename)
+	if err != nil {
+		return nil, err
 	}
 
-	cstLocal, _ = time.LoadLocation("Asia/Shanghai")
+	log := NewTcLogWithSinks(level, SinkConfig{Sink: fileLog})
+	log.fileLog = fileLog
 
-	isDir, err := IsDir(filepath)
-	if err != nil || !isDir {
-		err = os.MkdirAll(filepath, 0755)
-		if err != nil {
-			return nil, NewError("Mkdir failed, err:%v", err)
-		}
+	return log, nil
+}
+
+// NewTcLogWithSinks 创建写入多个输出的日志，如同时写入文件和标准输出
+func NewTcLogWithSinks(level string, sinks ...SinkConfig) *TcLog {
+	log := &TcLog{
+		core: &core{
+			enableFuncCallDepth: false,
+			logFuncCallDepth:    TcLogDefCallDepth,
+			queue:               newQueue(),
+			done:                make(chan struct{}),
+		},
 	}
 
-	log.level = log.levelFromStr(level)
-	log.fileLog.filename = filename
-	log.fileLog.filepath = filepath
+	log.level.Store(int32(log.levelFromStr(level)))
+	log.stackLevel.Store(LevelFatal + 1)
 	hostname, _ := os.Hostname()
 	log.hostname = hostname
 
-	err = log.fileLog.startLogger(true, true)
-	if err != nil {
-		return nil, err
+	for _, sink := range sinks {
+		log.AddSink(sink.Sink, sink.Level)
 	}
 
-	go log.fileLog.reopenCheck()
-
 	go log.startLogger()
 
-	return log, nil
+	return log
+}
+
+// With 返回带有fields的子日志，fields输出在每条日志的消息之后
+func (log *TcLog) With(fields ...Field) *TcLog {
+	child := &TcLog{
+		core:   log.core,
+		fields: make([]Field, 0, len(log.fields)+len(fields)),
+	}
+	child.fields = append(child.fields, log.fields...)
+	child.fields = append(child.fields, fields...)
+	return child
 }
 
 func (log *TcLog) levelFromStr(level string) int {
@@ -119,6 +146,8 @@ func (log *TcLog) levelFromStr(level string) int {
 		resultLevel = LevelNotice
 	case "warn":
 		resultLevel = LevelWarn
+	case "error":
+		resultLevel = LevelError
 	case "fatal":
 		resultLevel = LevelFatal
 	default:
@@ -128,11 +157,27 @@ func (log *TcLog) levelFromStr(level string) int {
 }
 
 func (log *TcLog) SetLevel(level string) {
-	log.level = log.levelFromStr(level)
+	log.level.Store(int32(log.levelFromStr(level)))
 }
 
 func (log *TcLog) GetLevel() string {
-	return levelTextArray[log.level]
+	return levelTextArray[log.level.Load()]
+}
+
+// SetEncoder 设置日志文件的编码格式，默认为NewTextEncoder(EncoderConfig{})，其他输出的编码格式在创建时指定
+func (log *TcLog) SetEncoder(enc Encoder) {
+	if log.fileLog != nil {
+		log.fileLog.SetEncoder(enc)
+	}
+}
+
+// EnableStacktrace level及以上级别的日志记录调用栈，level为空时关闭
+func (log *TcLog) EnableStacktrace(level string) {
+	if level == "" {
+		log.stackLevel.Store(LevelFatal + 1)
+		return
+	}
+	log.stackLevel.Store(int32(log.levelFromStr(level)))
 }
 
 func (log *TcLog) SetFuncCallDepth(depth int) {
@@ -144,35 +189,99 @@ func (log *TcLog) EnableFuncCallDepth(flag bool) {
 }
 
 func (log *TcLog) SetMaxDays(day int64) {
-	log.fileLog.maxDays = day
+	if log.fileLog != nil {
+		log.fileLog.SetMaxDays(day)
+	}
 }
 
 func (log *TcLog) GetMaxDays() int64 {
+	if log.fileLog == nil {
+		return 0
+	}
+
+	log.fileLog.lock.Lock()
+	defer log.fileLog.lock.Unlock()
 	return log.fileLog.maxDays
 }
 
 func (log *TcLog) SetMaxLines(line int) {
-	log.fileLog.maxLines = line
+	if log.fileLog != nil {
+		log.fileLog.SetMaxLines(line)
+	}
 }
 
 func (log *TcLog) GetMaxLines() int {
+	if log.fileLog == nil {
+		return 0
+	}
+
+	log.fileLog.lock.Lock()
+	defer log.fileLog.lock.Unlock()
 	return log.fileLog.maxLines
 }
 
 func (log *TcLog) SetMaxSize(size int) {
-	log.fileLog.maxSize = size
+	if log.fileLog != nil {
+		log.fileLog.SetMaxSize(size)
+	}
 }
 
 func (log *TcLog) GetMaxSize() int {
+	if log.fileLog == nil {
+		return 0
+	}
+
+	log.fileLog.lock.Lock()
+	defer log.fileLog.lock.Unlock()
 	return log.fileLog.maxSize
 }
 
 func (log *TcLog) EnableRotate(flag bool) {
-	log.fileLog.rotate = flag
+	if log.fileLog != nil {
+		log.fileLog.EnableRotate(flag)
+	}
 }
 
 func (log *TcLog) EnableDaily(flag bool) {
-	log.fileLog.daily = flag
+	if log.fileLog != nil {
+		log.fileLog.EnableDaily(flag)
+	}
+}
+
+// EnableBuffer 日志文件缓冲写入，见FileLog.EnableBuffer
+func (log *TcLog) EnableBuffer(size int, interval time.Duration) {
+	if log.fileLog != nil {
+		log.fileLog.EnableBuffer(size, interval)
+	}
+}
+
+// SetFileRoutes 修改级别到日志文件的映射，见FileLog.SetRoutes
+func (log *TcLog) SetFileRoutes(routes ...FileRoute) error {
+	if log.fileLog == nil {
+		return nil
+	}
+	return log.fileLog.SetRoutes(routes...)
+}
+
+// SetCompression 切分后的日志文件在后台压缩，见FileLog.SetCompression
+func (log *TcLog) SetCompression(c Compression) {
+	if log.fileLog != nil {
+		log.fileLog.SetCompression(c)
+	}
+}
+
+// SetMaxFiles 最多保留的切分文件数，见FileLog.SetMaxFiles
+func (log *TcLog) SetMaxFiles(n int) {
+	if log.fileLog != nil {
+		log.fileLog.SetMaxFiles(n)
+	}
+}
+
+// SetMaxTotalSize 切分文件的总大小上限，见FileLog.SetMaxTotalSize
+func (log *TcLog) SetMaxTotalSize(size int64) {
+	if log.fileLog != nil {
+		log.fileLog.SetMaxTotalSize(size)
+	}
 }
 
 func (log *TcLog) GetHost() string {
@@ -180,143 +289,166 @@ func (log *TcLog) GetHost() string {
 }
 
 func (log *TcLog) startLogger() {
-	gameOver := false
+	defer close(log.done)
+
+	var batch []*Entry
 	for {
-		select {
-		case lm := <-log.msgChan:
-			log.writeToFile(lm.when, lm.msg, lm.level)
-			logMsgPool.Put(lm)
-		case sg := <-log.signalChan:
-			// Now should only send "flush" or "close" to log.signalChan
-			log.flush()
-			if sg == "close" {
-				log.fileLog.Destroy()
-				gameOver = true
-			}
+		var (
+			flushes []chan struct{}
+			closing bool
+		)
+		batch, flushes, closing = log.queue.take(batch)
+
+		for _, e := range batch {
+			log.output(e)
 		}
-		if gameOver {
-			break
+		clear(batch)
+
+		if closing {
+			log.reportSampled()
+		}
+
+		force := len(flushes) > 0 || closing
+		log.reportDropped(force)
+
+		if force {
+			log.flushSinks()
+		}
+		for _, done := range flushes {
+			close(done)
+		}
+
+		if closing {
+			log.closeSinks()
+			return
 		}
 	}
 }
 
+// Flush 等待队列中的日志写入并刷新各输出
 func (log *TcLog) Flush() {
-	log.signalChan <- "flush"
+	done := make(chan struct{})
+	if log.queue.flush(done) {
+		<-done
+	}
 }
 
-// Close close logger, flush all chan data and destroy all adapters in BeeLogger.
+// Close 写入队列中的日志并关闭各输出，返回时日志已全部写入，之后的日志会被丢弃，
+// 子日志共用同一个TcLog，只需关闭一次
 func (log *TcLog) Close() {
-	log.signalChan <- "close"
-	close(log.msgChan)
-	close(log.signalChan)
+	log.queue.close()
+	<-log.done
 }
 
-func (log *TcLog) flush() {
-	for {
-		if len(log.msgChan) > 0 {
-			lm := <-log.msgChan
-			log.writeToFile(lm.when, lm.msg, lm.level)
-			logMsgPool.Put(lm)
-			continue
-		}
-		break
+// logf 按级别过滤后格式化消息，v末尾的Field作为结构化字段，
+// 有字段且没有格式化参数时format原样作为消息
+func (log *TcLog) logf(level, depth int, format string, v []interface{}) {
+	if level < int(log.level.Load()) || log.sampled(level, depth, format) {
+		return
 	}
-	log.fileLog.Flush()
-}
 
-func (log *TcLog) getRuntimeInfo(skip int) map[string]interface{} {
+	msg, fields := formatMessage(format, v)
+	log.write(level, depth, msg, fields)
+}
 
-	function := ""
-	pc, file, line, ok := runtime.Caller(skip)
-	if ok {
-		function = runtime.FuncForPC(pc).Name()
+// logPrefixf 与logf相同，prefix拼在消息之前，采样仍按format区分位置，供TcLogField使用
+func (log *TcLog) logPrefixf(level, depth int, prefix, format string, v []interface{}) {
+	if level < int(log.level.Load()) || log.sampled(level, depth, format) {
+		return
 	}
 
-	runInfo := make(map[string]interface{})
-	runInfo["xx_func"] = function
-	runInfo["xx_file"] = file
-	runInfo["xx_line"] = line
+	msg, fields := formatMessage(format, v)
+	log.write(level, depth, prefix+msg, fields)
+}
+
+func formatMessage(format string, v []interface{}) (string, []Field) {
+	args, fields := splitFields(v)
 
-	log.logFuncCallDepth = TcLogDefCallDepth
+	msg := format
+	if len(args) > 0 || len(fields) == 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
 
-	return runInfo
+	return msg, fields
 }
 
-func (log *TcLog) writeMsg(logLevel int, msg string) {
-	when := time.Now().In(cstLocal)
+func (log *TcLog) write(level, depth int, msg string, fields []Field) {
+	e := entryPool.Get().(*Entry)
+	e.Time = time.Now().In(cstLocal)
+	e.Level = level
+	e.Host = log.hostname
+	e.Message = msg
+	e.Caller = Caller{}
+	e.Stack = ""
+	e.Fields = append(append(e.Fields[:0], log.fields...), fields...)
+
 	if log.enableFuncCallDepth {
-		runInfo := log.getRuntimeInfo(log.logFuncCallDepth)
-		msg = fmt.Sprintf("func:%s file:%s line:%d %s", runInfo["xx_func"], runInfo["xx_file"], runInfo["xx_line"], msg)
+		pc, file, line, ok := runtime.Caller(depth)
+		if ok {
+			e.Caller = Caller{
+				Defined:  true,
+				Function: runtime.FuncForPC(pc).Name(),
+				File:     file,
+				Line:     line,
+			}
+		}
 	}
 
-	lm := logMsgPool.Get().(*logMsg)
-	lm.level = logLevel
-	lm.msg = msg
-	lm.when = when
+	if level >= int(log.stackLevel.Load()) {
+		e.Stack = stacktrace(depth + 1)
+	}
 
-	log.msgChan <- lm
+	if !log.queue.put(e) {
+		releaseEntry(e)
+	}
 }
 
-func (log *TcLog) writeToFile(when time.Time, msg string, level int) {
-	log.fileLog.WriteMsg(log.hostname, when, msg, level)
+// stacktrace 返回跳过skip层之后的调用栈，格式与debug.Stack相同
+func stacktrace(skip int) string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
 }
 
-// DEBUG Log DEBUG level message.
+// Debug 输出Debug级别日志，v末尾可以跟Field:
+//
+//	log.Debug("user login", tclog.String("uid", uid), tclog.Err(err))
 func (log *TcLog) Debug(format string, v ...interface{}) {
-	if LevelDebug < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelDebug, msg)
+	log.logf(LevelDebug, log.logFuncCallDepth, format, v)
 }
 
-// INFO Log INFO level message.
 func (log *TcLog) Info(format string, v ...interface{}) {
-	if LevelInfo < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelInfo, msg)
+	log.logf(LevelInfo, log.logFuncCallDepth, format, v)
 }
 
-// Notice Log Notice level message.
 func (log *TcLog) Notice(format string, v ...interface{}) {
-	if LevelNotice < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelNotice, msg)
+	log.logf(LevelNotice, log.logFuncCallDepth, format, v)
 }
 
-// Warn Log Warn level message.
 func (log *TcLog) Warn(format string, v ...interface{}) {
-	if LevelWarn < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelWarn, msg)
+	log.logf(LevelWarn, log.logFuncCallDepth, format, v)
 }
 
-// Error Log Error level message.
 func (log *TcLog) Error(format string, v ...interface{}) {
-	if LevelError < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelError, msg)
+	log.logf(LevelError, log.logFuncCallDepth, format, v)
 }
 
-// Fatal Log Fatal level message.
 func (log *TcLog) Fatal(format string, v ...interface{}) {
-	if LevelFatal < log.level {
-		return
-	}
-	msg := fmt.Sprintf(format, v...)
-	log.writeMsg(LevelFatal, msg)
+	log.logf(LevelFatal, log.logFuncCallDepth, format, v)
 }
 
+// Output 与标准库log.Logger.Output相同，以Warn级别输出
 func (log *TcLog) Output(calldepth int, s string) error {
-	log.SetFuncCallDepth(calldepth)
-	log.writeMsg(LevelWarn, s)
+	log.write(LevelWarn, calldepth-1, s, nil)
 	return nil
 }
@@ -4,8 +4,10 @@ import (
 	"fmt"
 )
 
+// TcLogField 保留兼容，字段以[value]或[key: value]的形式按设置顺序输出在消息之前，
+// 新代码请使用TcLog.With及Field
 type TcLogField struct {
-	fields           map[string]string
+	fields           []Field
 	logger           *TcLog
 	logFuncCallDepth int
 	ignoreKey        bool
@@ -13,7 +15,6 @@ type TcLogField struct {
 
 func NewTcLogField(logger *TcLog) *TcLogField {
 	return &TcLogField{
-		fields:           make(map[string]string),
 		logger:           logger,
 		logFuncCallDepth: TcLogDefCallDepth + 1,
 		ignoreKey:        true,
@@ -21,36 +22,39 @@ func NewTcLogField(logger *TcLog) *TcLogField {
 }
 
 func (logField *TcLogField) Clone() *TcLogField {
-	cloneField := &TcLogField{
-		fields:           make(map[string]string),
-		logger:           logField.logger,
-		logFuncCallDepth: logField.logFuncCallDepth,
-		ignoreKey:        logField.ignoreKey,
-	}
-
-	for key, value := range logField.fields {
-		cloneField.fields[key] = value
-	}
-
-	return cloneField
+	cloneField := *logField
+	cloneField.fields = append([]Field(nil), logField.fields...)
+	return &cloneField
 }
 
+// SetIgnoreKey 默认只输出[value]，设置为false时输出[key: value]
 func (logField *TcLogField) SetIgnoreKey(enable bool) {
 	logField.ignoreKey = enable
 }
 
+// Set 设置字段，key已存在时保持原有顺序
 func (logField *TcLogField) Set(key string, format string, values ...interface{}) {
-	logField.fields[key] = fmt.Sprintf(format, values...)
+	field := String(key, fmt.Sprintf(format, values...))
+	for i := range logField.fields {
+		if logField.fields[i].Key == key {
+			logField.fields[i] = field
+			return
+		}
+	}
+	logField.fields = append(logField.fields, field)
 }
 
 func (logField *TcLogField) Del(key string) {
-	delete(logField.fields, key)
+	for i := range logField.fields {
+		if logField.fields[i].Key == key {
+			logField.fields = append(logField.fields[:i], logField.fields[i+1:]...)
+			return
+		}
+	}
 }
 
 func (logField *TcLogField) ClearFields() {
-	for key, _ := range logField.fields {
-		delete(logField.fields, key)
-	}
+	logField.fields = logField.fields[:0]
 }
 
 func (logField *TcLogField) IncrDepth(depth int) {
@@ -58,109 +62,43 @@ func (logField *TcLogField) IncrDepth(depth int) {
 }
 
 func (logField *TcLogField) Debug(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
-		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Debug(msg, v...)
-	}
+	logField.log(LevelDebug, format, v)
 }
 
 func (logField *TcLogField) Info(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
-		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Info(msg, v...)
-	}
+	logField.log(LevelInfo, format, v)
 }
 
 func (logField *TcLogField) Notice(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
-		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Notice(msg, v...)
-	}
+	logField.log(LevelNotice, format, v)
 }
 
 func (logField *TcLogField) Warn(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
-		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Warn(msg, v...)
-	}
+	logField.log(LevelWarn, format, v)
 }
 
 func (logField *TcLogField) Error(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
-		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Error(msg, v...)
-	}
+	logField.log(LevelError, format, v)
 }
 
 func (logField *TcLogField) Fatal(format string, v ...interface{}) {
-	if logField.logger != nil {
-		msg := ""
-		if logField.logger.enableFuncCallDepth {
-			logField.logger.logFuncCallDepth = logField.logFuncCallDepth
-		}
-		for key, value := range logField.fields {
-			if logField.ignoreKey {
-				msg = fmt.Sprintf("%s [%s]", msg, value)
-			} else {
-				msg = fmt.Sprintf("%s [%s: %s]", msg, key, value)
-			}
+	logField.log(LevelFatal, format, v)
+}
+
+func (logField *TcLogField) log(level int, format string, v []interface{}) {
+	if logField.logger == nil {
+		return
+	}
+
+	prefix := make([]byte, 0, 64)
+	for _, field := range logField.fields {
+		if logField.ignoreKey {
+			prefix = fmt.Appendf(prefix, " [%s]", field.s)
+		} else {
+			prefix = fmt.Appendf(prefix, " [%s: %s]", field.Key, field.s)
 		}
-		msg = fmt.Sprintf("%s %s", msg, format)
-		logField.logger.Fatal(msg, v...)
 	}
+	prefix = append(prefix, ' ')
+
+	logField.logger.logPrefixf(level, logField.logFuncCallDepth, string(prefix), format, v)
 }
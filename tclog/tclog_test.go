@@ -1,12 +1,16 @@
 package tclog
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestTcLogger(t *testing.T) {
-	logger, err := NewTcLog("D://log/tclog", "tclog_test", "debug")
+	logger, err := NewTcLog(t.TempDir(), "tclog_test", "debug")
 	if err != nil {
 		t.Fatalf("logger open failed, %v", err)
 	}
@@ -25,6 +29,77 @@ func TestTcLogger(t *testing.T) {
 	cloneField := field.Clone()
 	cloneField.Fatal("test fatal clone field")
 
-	time.Sleep(time.Second)
 	logger.Close()
 }
+
+func TestStructuredLog(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := NewTcLog(dir, "structured", "info")
+	if err != nil {
+		t.Fatalf("logger open failed, %v", err)
+	}
+
+	reqLog := logger.With(String("request_id", "r-1"), Int("shard", 3))
+
+	logger.Debug("filtered", String("k", "v"))
+	logger.Info("plain 100%")
+	logger.Info("user login", String("uid", "u1"), Bool("admin", false), Duration("cost", 1500*time.Millisecond))
+	logger.Info("retry %d/%d", 1, 3, Float64("backoff", 0.5))
+	reqLog.Info("query done", Int64("rows", 42), String("sql", "select * from t"))
+	reqLog.With(Uint64("page", 2)).Info("next page", Err(nil))
+	reqLog.Error("query failed", Err(errors.New("connection reset")), Any("hosts", []string{"a", "b"}))
+
+	field := NewTcLogField(logger)
+	field.SetIgnoreKey(false)
+	field.Set("logid", "%d", 1)
+	field.Set("userid", "%s", "u2")
+	field.Set("logid", "%d", 2)
+	field.Info("legacy field")
+
+	valueField := NewTcLogField(logger)
+	valueField.Set("logid", "%d", 3)
+	valueField.Set("userid", "%s", "u3")
+	valueField.Info("legacy %s", "value", Int("n", 1))
+
+	logger.Close()
+
+	tests := []struct {
+		file  string
+		lines []string
+	}{
+		{
+			file: "structured.log",
+			lines: []string{
+				"INFO plain 100%!(NOVERB)",
+				"INFO user login uid=u1 admin=false cost=1.5s",
+				"INFO retry 1/3 backoff=0.5",
+				`INFO query done request_id=r-1 shard=3 rows=42 sql="select * from t"`,
+				"INFO next page request_id=r-1 shard=3 page=2",
+				"INFO  [logid: 2] [userid: u2] legacy field",
+				"INFO  [3] [u3] legacy value n=1",
+			},
+		},
+		{
+			file:  "structured.log.wf",
+			lines: []string{`ERROR query failed request_id=r-1 shard=3 error="connection reset" hosts="[a b]"`},
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatalf("read %s failed, %v", tt.file, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != len(tt.lines) {
+			t.Fatalf("%s: expected %d lines, got %q", tt.file, len(tt.lines), lines)
+		}
+
+		for i, line := range lines {
+			if !strings.HasSuffix(line, tt.lines[i]) {
+				t.Fatalf("%s: expected line %d ending with %q, got %q", tt.file, i, tt.lines[i], line)
+			}
+		}
+	}
+}